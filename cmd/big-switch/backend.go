@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"github.com/callebjorkell/big-switch/internal/deploy"
+	"github.com/callebjorkell/big-switch/internal/deploy/argocd"
+	"github.com/callebjorkell/big-switch/internal/deploy/github"
+	"github.com/callebjorkell/big-switch/internal/deploy/rest"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// backendSet holds the backends created from the config.
+type backendSet struct {
+	byName map[string]deploy.Backend
+	github *github.Client
+}
+
+// newBackends creates every backend declared in the config. The release-manager backend is only created if some service
+// uses it.
+func newBackends(conf *Config) (*backendSet, error) {
+	backends := make(map[string]deploy.Backend)
+	if conf.usesBackend(releaseManagerBackend) {
+		backends[releaseManagerBackend] = newDeployClient(conf)
+	}
+
+	for _, b := range conf.Backends {
+		switch b.Type {
+		case backendTypeRest:
+			r := b.Rest
+			backend, err := rest.NewBackend(rest.Config{
+				Headers: r.Headers,
+				Status: rest.StatusConfig{
+					Url:      r.Status.Url,
+					Artifact: r.Status.Artifact,
+					Time:     r.Status.Time,
+					Author:   r.Status.Author,
+				},
+				Promote: rest.PromoteConfig{
+					Method: r.Promote.Method,
+					Url:    r.Promote.Url,
+					Body:   r.Promote.Body,
+				},
+			})
+			if err != nil {
+				return nil, fmt.Errorf("backend %v: %w", b.Name, err)
+			}
+			if r.Timeout > 0 {
+				backend.HttpClient.Timeout = time.Duration(r.Timeout) * time.Second
+			}
+			backends[b.Name] = backend
+		case backendTypeArgoCD:
+			a := b.ArgoCD
+			backend, err := argocd.NewBackend(argocd.Config{
+				Url:         a.Url,
+				Token:       a.Token,
+				Application: a.Application,
+				Mode:        argocd.Mode(a.Mode),
+			})
+			if err != nil {
+				return nil, fmt.Errorf("backend %v: %w", b.Name, err)
+			}
+			if a.Timeout > 0 {
+				backend.HttpClient.Timeout = time.Duration(a.Timeout) * time.Second
+			}
+			backends[b.Name] = backend
+		}
+	}
+
+	set := &backendSet{byName: backends}
+	if gh := conf.GitHub; gh.Token != "" {
+		url := gh.Url
+		if url == "" {
+			url = github.DefaultUrl
+		}
+		set.github = github.NewClient(url, gh.Token)
+		if gh.PollInterval > 0 {
+			set.github.PollInterval = time.Duration(gh.PollInterval) * time.Second
+		}
+		if gh.Timeout > 0 {
+			set.github.Timeout = time.Duration(gh.Timeout) * time.Second
+		}
+	}
+
+	return set, nil
+}
+
+// route routes the service to its configured backend. Services with a GitHub workflow are still watched through the
+// backend, but are promoted by running the workflow.
+func (s *backendSet) route(router *deploy.Router, service ServiceConfig) error {
+	b, ok := s.byName[service.Backend]
+	if !ok {
+		return fmt.Errorf("backend %v of %v has not been started", service.Backend, service.Name)
+	}
+
+	if gh := service.GitHub; gh.Repo != "" {
+		if s.github == nil {
+			return fmt.Errorf("github workflow of %v needs a github token set at startup", service.Name)
+		}
+		log.Debugf("Routing %v to backend %v, promoting with %v in %v", service.Name, service.Backend, gh.Workflow, gh.Repo)
+		router.Route(service.Name, deploy.Combine(b, github.NewDeployer(s.github, github.Workflow{
+			Repo:     gh.Repo,
+			Workflow: gh.Workflow,
+			Ref:      gh.Ref,
+			Input:    gh.Input,
+		})))
+		return nil
+	}
+
+	log.Debugf("Routing %v to backend %v", service.Name, service.Backend)
+	router.Route(service.Name, b)
+	return nil
+}
@@ -1,9 +1,15 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"github.com/callebjorkell/big-switch/internal/deploy"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
 )
 
 func RootCmd() *cobra.Command {
@@ -21,6 +27,7 @@ func RootCmd() *cobra.Command {
 	rootCmd.AddCommand(newStartCmd())
 	rootCmd.AddCommand(newEncryptCmd())
 	rootCmd.AddCommand(newDecryptCmd())
+	rootCmd.AddCommand(newAuditCmd())
 	rootCmd.PersistentFlags().Bool("debug", false, "Turn on debug logging.")
 
 	return rootCmd
@@ -93,3 +100,63 @@ func newDecryptCmd() *cobra.Command {
 
 	return &cmd
 }
+
+func newAuditCmd() *cobra.Command {
+	file := defaultAuditFile
+	filter := deploy.AuditFilter{}
+	outcome := ""
+	since := time.Duration(0)
+	asJson := false
+	cmd := cobra.Command{
+		Use:   "audit",
+		Short: "List the entries of the audit log, oldest first",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			filter.Outcome = deploy.AuditOutcome(outcome)
+			if since > 0 {
+				filter.Since = time.Now().Add(-since)
+			}
+			entries, err := deploy.ReadAuditLog(file, filter.Match)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if asJson {
+				enc := json.NewEncoder(os.Stdout)
+				for _, e := range entries {
+					if err := enc.Encode(e); err != nil {
+						log.Fatal(err)
+					}
+				}
+				return
+			}
+			printAuditEntries(entries)
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "file", "f", defaultAuditFile, "Read the audit log from the given file.")
+	cmd.Flags().StringVarP(&filter.Service, "service", "s", "", "Only list entries for the given service.")
+	cmd.Flags().StringVarP(&outcome, "outcome", "o", "", "Only list entries with the given outcome (confirmed, failed, skipped or \"timed out\").")
+	cmd.Flags().DurationVar(&since, "since", 0, "Only list entries written within the given duration, like 24h.")
+	cmd.Flags().BoolVar(&asJson, "json", false, "Print the entries as JSON lines.")
+
+	return &cmd
+}
+
+func printAuditEntries(entries []deploy.AuditEntry) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tSERVICE\tPROMOTION\tSOURCE\tTARGET\tAUTHOR\tOUTCOME\tBY\tRESPONSE\tERROR")
+	for _, e := range entries {
+		outcome := string(e.Outcome)
+		if e.Rollback {
+			outcome = "rollback " + outcome
+		}
+		by := e.Source
+		if len(e.Approvers) > 0 {
+			by = strings.Join(e.Approvers, ",")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s->%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			e.Time.Local().Format("2006-01-02 15:04:05"), e.Service, e.From, e.To, e.SourceArtifact, e.TargetArtifact,
+			e.Author, outcome, by, e.Response, e.Error)
+	}
+	w.Flush()
+}
@@ -2,33 +2,233 @@ package main
 
 import (
 	"fmt"
+	"github.com/callebjorkell/big-switch/internal/deploy"
+	"github.com/callebjorkell/big-switch/internal/neopixel"
 	"gopkg.in/yaml.v3"
+	"reflect"
+	"strings"
+	"time"
 )
 
 const (
 	defaultPollingInterval = 30
 	defaultWarmupDuration  = 120
+	defaultStateFile       = "state.json"
+	defaultAuditFile       = "audit.log"
+	defaultRolloutInterval = 10
+	// releaseManagerBackend is the name of the backend configured in the releaseManager section, used by services that
+	// do not name a backend.
+	releaseManagerBackend = "releaseManager"
+	backendTypeRest       = "rest"
+	backendTypeArgoCD     = "argocd"
+
+	// events that LED patterns can be configured for. Only the alert, success and failure patterns can be configured
+	// per service.
+	patternAlert   = "alert"
+	patternSuccess = "success"
+	patternFailure = "failure"
+	patternStartup = "startup"
+	patternRestart = "restart"
 )
 
 type Config struct {
-	RestartCron   string `yaml:"restartCron"`
-	AlertDuration int    `yaml:"alertDuration"`
-	Authors       []struct {
+	RestartCron    string `yaml:"restartCron"`
+	AlertDuration  int    `yaml:"alertDuration"`
+	RollbackWindow int    `yaml:"rollbackWindow"`
+	StateFile      string `yaml:"stateFile"`
+	AuditFile      string `yaml:"auditFile"`
+	Authors        []struct {
 		FullName string `yaml:"fullName"`
 		Alias    string `yaml:"alias"`
 	} `yaml:"authors"`
 	ReleaseManager struct {
-		Url    string `yaml:"url"`
-		Token  string `yaml:"token"`
-		Caller string `yaml:"caller"`
+		Url          string `yaml:"url"`
+		Token        string `yaml:"token"`
+		Caller       string `yaml:"caller"`
+		Timeout      int    `yaml:"timeout"`
+		Retries      int    `yaml:"retries"`
+		RetryBackoff int    `yaml:"retryBackoff"`
 	} `yaml:"releaseManager"`
-	Services []struct {
-		Name            string `yaml:"name"`
-		Namespace       string `yaml:"namespace"`
-		Color           uint32 `yaml:"color"`
-		WarmupDuration  int    `yaml:"warmupDuration"`
-		PollingInterval int    `yaml:"pollingInterval"`
-	} `yaml:"services"`
+	Api struct {
+		Address string `yaml:"address"`
+		Token   string `yaml:"token"`
+		Webhook bool   `yaml:"webhook"`
+	} `yaml:"api"`
+	Chat struct {
+		Webhooks []string `yaml:"webhooks"`
+		Username string   `yaml:"username"`
+	} `yaml:"chat"`
+	Rollout struct {
+		Timeout  int `yaml:"timeout"`
+		Interval int `yaml:"interval"`
+	} `yaml:"rollout"`
+	GitHub struct {
+		Url          string `yaml:"url"`
+		Token        string `yaml:"token"`
+		PollInterval int    `yaml:"pollInterval"`
+		Timeout      int    `yaml:"timeout"`
+	} `yaml:"github"`
+	Approvals struct {
+		Button     string `yaml:"button"`
+		ButtonName string `yaml:"buttonName"`
+		Approvers  []struct {
+			Name   string `yaml:"name"`
+			Secret string `yaml:"secret"`
+		} `yaml:"approvers"`
+	} `yaml:"approvals"`
+	Freezes  []FreezeConfig           `yaml:"freezes"`
+	Patterns map[string]PatternConfig `yaml:"patterns"`
+	Backends []BackendConfig          `yaml:"backends"`
+	Services []ServiceConfig          `yaml:"services"`
+
+	// freezeWindows are parsed from Freezes.
+	freezeWindows []deploy.FreezeWindow
+	// patterns are parsed from Patterns, and the patterns of the services.
+	patterns ledPatterns
+}
+
+type FreezeConfig struct {
+	Name          string        `yaml:"name"`
+	Cron          string        `yaml:"cron"`
+	Duration      time.Duration `yaml:"duration"`
+	From          time.Time     `yaml:"from"`
+	To            time.Time     `yaml:"to"`
+	Services      []string      `yaml:"services"`
+	AllowOverride bool          `yaml:"allowOverride"`
+}
+
+// Window creates the freeze window described by the config.
+func (f FreezeConfig) Window() (deploy.FreezeWindow, error) {
+	var w deploy.FreezeWindow
+	var err error
+	switch {
+	case f.Cron != "" && f.From.IsZero() && f.To.IsZero():
+		w, err = deploy.NewCronFreezeWindow(f.Name, f.Cron, f.Duration)
+	case f.Cron == "" && !f.From.IsZero() && !f.To.IsZero():
+		w, err = deploy.NewDateFreezeWindow(f.Name, f.From, f.To)
+	default:
+		err = fmt.Errorf("freeze %v must have either a cron and a duration, or a from and a to", f.Name)
+	}
+	if err != nil {
+		return deploy.FreezeWindow{}, err
+	}
+	w.Services = f.Services
+	w.AllowOverride = f.AllowOverride
+	return w, nil
+}
+
+// PatternConfig describes an LED pattern as a list of keyframes.
+type PatternConfig struct {
+	Repeat    bool `yaml:"repeat"`
+	Keyframes []struct {
+		Color uint32 `yaml:"color"`
+		// Brightness is a pointer to tell a brightness of 0 apart from one that is not set.
+		Brightness *uint32         `yaml:"brightness"`
+		Duration   time.Duration   `yaml:"duration"`
+		Easing     neopixel.Easing `yaml:"easing"`
+	} `yaml:"keyframes"`
+}
+
+// Pattern creates the LED pattern described by the config. Keyframes are at full brightness unless anything else is
+// set.
+func (p PatternConfig) Pattern() (neopixel.Pattern, error) {
+	pattern := neopixel.Pattern{Repeat: p.Repeat}
+	for _, k := range p.Keyframes {
+		brightness := uint32(100)
+		if k.Brightness != nil {
+			brightness = *k.Brightness
+		}
+		pattern.Keyframes = append(pattern.Keyframes, neopixel.Keyframe{
+			Color:      k.Color,
+			Brightness: brightness,
+			Duration:   k.Duration,
+			Easing:     k.Easing,
+		})
+	}
+	return pattern, pattern.Validate()
+}
+
+// ledPatterns are the LED patterns configured for events, with the patterns configured for services taking precedence.
+type ledPatterns struct {
+	events   map[string]neopixel.Pattern
+	services map[string]map[string]neopixel.Pattern
+}
+
+// get the pattern configured for the event of the service. Use an empty service for events that are not about one.
+func (p ledPatterns) get(service, event string) (neopixel.Pattern, bool) {
+	if pattern, ok := p.services[service][event]; ok {
+		return pattern, true
+	}
+	pattern, ok := p.events[event]
+	return pattern, ok
+}
+
+// parsePatterns parses the configs of the patterns, keyed by event, and checks that they are for known events.
+func parsePatterns(configs map[string]PatternConfig, events ...string) (map[string]neopixel.Pattern, error) {
+	patterns := make(map[string]neopixel.Pattern)
+	for event, config := range configs {
+		known := false
+		for _, e := range events {
+			known = known || e == event
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown event %q, patterns can be configured for %v", event, strings.Join(events, ", "))
+		}
+		pattern, err := config.Pattern()
+		if err != nil {
+			return nil, fmt.Errorf("invalid %v pattern: %w", event, err)
+		}
+		patterns[event] = pattern
+	}
+	return patterns, nil
+}
+
+type BackendConfig struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"`
+	Rest struct {
+		Headers map[string]string `yaml:"headers"`
+		Timeout int               `yaml:"timeout"`
+		Status  struct {
+			Url      string `yaml:"url"`
+			Artifact string `yaml:"artifact"`
+			Time     string `yaml:"time"`
+			Author   string `yaml:"author"`
+		} `yaml:"status"`
+		Promote struct {
+			Method string `yaml:"method"`
+			Url    string `yaml:"url"`
+			Body   string `yaml:"body"`
+		} `yaml:"promote"`
+	} `yaml:"rest"`
+	ArgoCD struct {
+		Url         string `yaml:"url"`
+		Token       string `yaml:"token"`
+		Application string `yaml:"application"`
+		Mode        string `yaml:"mode"`
+		Timeout     int    `yaml:"timeout"`
+	} `yaml:"argocd"`
+}
+
+type ServiceConfig struct {
+	Name             string `yaml:"name"`
+	Namespace        string `yaml:"namespace"`
+	Backend          string `yaml:"backend"`
+	RequireApprovals int    `yaml:"requireApprovals"`
+	Color            uint32 `yaml:"color"`
+	WarmupDuration   int    `yaml:"warmupDuration"`
+	PollingInterval  int    `yaml:"pollingInterval"`
+	Promotions       []struct {
+		From string `yaml:"from"`
+		To   string `yaml:"to"`
+	} `yaml:"promotions"`
+	GitHub struct {
+		Repo     string `yaml:"repo"`
+		Workflow string `yaml:"workflow"`
+		Ref      string `yaml:"ref"`
+		Input    string `yaml:"input"`
+	} `yaml:"github"`
+	Patterns map[string]PatternConfig `yaml:"patterns"`
 }
 
 func (c Config) ColorMap() map[string]uint32 {
@@ -39,6 +239,15 @@ func (c Config) ColorMap() map[string]uint32 {
 	return colors
 }
 
+// ApproverMap maps the names of the approvers to their secrets.
+func (c Config) ApproverMap() map[string]string {
+	approvers := make(map[string]string)
+	for _, a := range c.Approvals.Approvers {
+		approvers[a.Name] = a.Secret
+	}
+	return approvers
+}
+
 func (c Config) AuthorMap() map[string]string {
 	authors := make(map[string]string)
 	for _, author := range c.Authors {
@@ -47,6 +256,28 @@ func (c Config) AuthorMap() map[string]string {
 	return authors
 }
 
+// PromotionChain is the chain of promotions that the service should be watched for. Services that do not declare a
+// chain are promoted from dev to prod.
+func (s ServiceConfig) PromotionChain() []deploy.Promotion {
+	if len(s.Promotions) == 0 {
+		return []deploy.Promotion{deploy.DefaultPromotion}
+	}
+	promotions := make([]deploy.Promotion, 0, len(s.Promotions))
+	for _, p := range s.Promotions {
+		promotions = append(promotions, deploy.Promotion{From: p.From, To: p.To})
+	}
+	return promotions
+}
+
+// WatchEquals reports whether the two service configs would be watched in the same way, ignoring settings that only
+// change how releases are shown.
+func (s ServiceConfig) WatchEquals(o ServiceConfig) bool {
+	s.Color, o.Color = 0, 0
+	s.RequireApprovals, o.RequireApprovals = 0, 0
+	s.Patterns, o.Patterns = nil, nil
+	return reflect.DeepEqual(s, o)
+}
+
 func parseConfig(content []byte) (*Config, error) {
 	c := &Config{}
 	err := yaml.Unmarshal(content, c)
@@ -54,29 +285,111 @@ func parseConfig(content []byte) (*Config, error) {
 		return nil, err
 	}
 
-	if c.ReleaseManager.Token == "" {
-		return nil, fmt.Errorf("release manager token is missing")
+	if c.StateFile == "" {
+		c.StateFile = defaultStateFile
 	}
-	if c.ReleaseManager.Url == "" {
-		return nil, fmt.Errorf("release manager URL is missing")
+	if c.AuditFile == "" {
+		c.AuditFile = defaultAuditFile
 	}
-	if c.ReleaseManager.Caller == "" {
-		return nil, fmt.Errorf("release manager caller is missing")
+	for i, f := range c.Freezes {
+		if f.Name == "" {
+			return nil, fmt.Errorf("name of freeze must be specified for entry %d", i)
+		}
+		w, err := f.Window()
+		if err != nil {
+			return nil, err
+		}
+		c.freezeWindows = append(c.freezeWindows, w)
+	}
+	c.patterns.events, err = parsePatterns(c.Patterns, patternAlert, patternSuccess, patternFailure, patternStartup, patternRestart)
+	if err != nil {
+		return nil, err
+	}
+	c.patterns.services = make(map[string]map[string]neopixel.Pattern)
+	if c.Approvals.Button != "" && c.Approvals.ButtonName == "" {
+		// the name is recorded as the committer of the release, so it should be the person that the button belongs to.
+		return nil, fmt.Errorf("buttonName of the approval button must be specified")
+	}
+	if c.Rollout.Interval <= 0 {
+		c.Rollout.Interval = defaultRolloutInterval
+	}
+
+	backends := map[string]bool{releaseManagerBackend: true}
+	for i, backend := range c.Backends {
+		if backend.Name == "" {
+			return nil, fmt.Errorf("name of backend must be specified for entry %d", i)
+		}
+		if backends[backend.Name] {
+			return nil, fmt.Errorf("backend %v is declared more than once", backend.Name)
+		}
+		if backend.Type != backendTypeRest && backend.Type != backendTypeArgoCD {
+			return nil, fmt.Errorf("backend %v has unknown type %q", backend.Name, backend.Type)
+		}
+		backends[backend.Name] = true
 	}
+
 	for i, service := range c.Services {
 		if len(service.Name) < 1 {
 			return nil, fmt.Errorf("name of service must be specified for entry %d", i)
 		}
+		if service.Backend == "" {
+			c.Services[i].Backend = releaseManagerBackend
+		} else if !backends[service.Backend] {
+			return nil, fmt.Errorf("service %v uses undeclared backend %v", service.Name, service.Backend)
+		}
 		if service.Color == 0 {
 			return nil, fmt.Errorf("color of service must be specified for entry %d", i)
 		}
+		if c.patterns.services[service.Name], err = parsePatterns(service.Patterns, patternAlert, patternSuccess, patternFailure); err != nil {
+			return nil, fmt.Errorf("service %v: %w", service.Name, err)
+		}
 		if service.PollingInterval <= 0 {
 			c.Services[i].PollingInterval = defaultPollingInterval
 		}
 		if service.WarmupDuration <= 0 {
 			c.Services[i].WarmupDuration = defaultWarmupDuration
 		}
+		if service.RequireApprovals > 1 && c.Approvals.Button == "" && len(c.Approvals.Approvers) == 0 {
+			return nil, fmt.Errorf("service %v requires approvals, but no approval button or approvers are configured", service.Name)
+		}
+		if gh := service.GitHub; gh.Repo != "" || gh.Workflow != "" {
+			if gh.Repo == "" || gh.Workflow == "" || gh.Ref == "" {
+				return nil, fmt.Errorf("github workflow of service %v must specify repo, workflow and ref", service.Name)
+			}
+			if c.GitHub.Token == "" {
+				return nil, fmt.Errorf("github token is missing for the workflow of service %v", service.Name)
+			}
+		}
+		for j, p := range service.Promotions {
+			if p.From == "" || p.To == "" {
+				return nil, fmt.Errorf("promotion %d of service %v must specify both from and to", j, service.Name)
+			}
+			if p.From == p.To {
+				return nil, fmt.Errorf("promotion %d of service %v cannot promote %v to itself", j, service.Name, p.From)
+			}
+		}
+	}
+
+	if c.usesBackend(releaseManagerBackend) {
+		if c.ReleaseManager.Token == "" {
+			return nil, fmt.Errorf("release manager token is missing")
+		}
+		if c.ReleaseManager.Url == "" {
+			return nil, fmt.Errorf("release manager URL is missing")
+		}
+		if c.ReleaseManager.Caller == "" {
+			return nil, fmt.Errorf("release manager caller is missing")
+		}
 	}
 
 	return c, nil
 }
+
+func (c Config) usesBackend(name string) bool {
+	for _, service := range c.Services {
+		if service.Backend == name {
+			return true
+		}
+	}
+	return false
+}
@@ -8,7 +8,9 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"fmt"
+	"github.com/callebjorkell/big-switch/internal/api"
 	"github.com/callebjorkell/big-switch/internal/button"
+	"github.com/callebjorkell/big-switch/internal/chat"
 	"github.com/callebjorkell/big-switch/internal/deploy"
 	"github.com/callebjorkell/big-switch/internal/lcd"
 	"github.com/callebjorkell/big-switch/internal/neopixel"
@@ -105,15 +107,16 @@ func startServer(encryptedConfig bool) {
 	ctx, cancel := ContextWithCancelOnSignal()
 	defer cancel()
 
-	lcd.InitLCD()
-	lcd.Reset()
+	display := lcd.NewMarquee(lcd.New(), lcd.DefaultScrollInterval)
+	defer display.Close()
+	lcd.Reset(display)
 
 	led := neopixel.NewLedController()
 	defer led.Close()
 
-	conf, err := readConfig(ctx, encryptedConfig)
+	conf, source, err := readConfig(ctx, display, encryptedConfig)
 	if err != nil {
-		lcd.Print("Failed to start!", "")
+		lcd.Print(display, "Failed to start!", "")
 		led.Flash(neopixel.ColorRed)
 		// sleep to throttle retries (restarts)
 		<-time.After(5 * time.Second)
@@ -126,11 +129,15 @@ func startServer(encryptedConfig bool) {
 		c := cron.New()
 		_, err := c.AddFunc(conf.RestartCron, func() {
 			log.Infof("Executing scheduled restart at %v", time.Now())
-			led.QuickFlash(neopixel.ColorYellow)
+			if pattern, ok := conf.patterns.get("", patternRestart); ok {
+				led.Play(pattern)
+			} else {
+				led.QuickFlash(neopixel.ColorYellow)
+			}
 			cancel()
 		})
 		if err != nil {
-			lcd.Print("Failed to setup", "kill switch")
+			lcd.Print(display, "Failed to setup", "kill switch")
 			led.Flash(neopixel.ColorRed)
 			// sleep to throttle retries (restarts)
 			<-time.After(5 * time.Second)
@@ -142,31 +149,152 @@ func startServer(encryptedConfig bool) {
 		log.Info("Restart cron is not set in config. Kill switch inactive.")
 	}
 
-	go led.Rainbow()
+	state, err := deploy.OpenStateStore(conf.StateFile)
+	if err != nil {
+		lcd.Print(display, "Failed to read", "state file")
+		led.Flash(neopixel.ColorRed)
+		// sleep to throttle retries (restarts)
+		<-time.After(5 * time.Second)
+		log.Fatalf("State could not be read from %v: %v", conf.StateFile, err)
+		return
+	}
+
+	audit, err := deploy.OpenAuditLog(conf.AuditFile)
+	if err != nil {
+		lcd.Print(display, "Failed to open", "audit log")
+		led.Flash(neopixel.ColorRed)
+		// sleep to throttle retries (restarts)
+		<-time.After(5 * time.Second)
+		log.Fatalf("Audit log could not be opened at %v: %v", conf.AuditFile, err)
+		return
+	}
+	defer audit.Close()
+
+	backends, err := newBackends(conf)
+	if err != nil {
+		lcd.Print(display, "Failed to setup", "backends")
+		led.Flash(neopixel.ColorRed)
+		// sleep to throttle retries (restarts)
+		<-time.After(5 * time.Second)
+		log.Fatalf("Backends could not be created: %v", err)
+		return
+	}
+
+	if pattern, ok := conf.patterns.get("", patternStartup); ok {
+		go led.Play(pattern)
+	} else {
+		go led.Rainbow()
+	}
 
-	deployClient := deploy.NewClient(conf.ReleaseManager.Url, conf.ReleaseManager.Token, conf.ReleaseManager.Caller)
-	watcher := deploy.NewWatcher(deployClient)
-	promoter := deploy.NewPromoter(deployClient)
+	router := deploy.NewRouter(nil)
+	watcher := deploy.NewWatcher(router, state)
 
 	for _, service := range conf.Services {
-		pollingInterval := time.Duration(service.PollingInterval) * time.Second
-		warmupDuration := time.Duration(service.WarmupDuration) * time.Second
-		watcher.AddWatch(service.Name, service.Namespace, pollingInterval, warmupDuration)
+		if err := backends.route(router, service); err != nil {
+			log.Warn(err)
+			continue
+		}
+		addWatches(watcher, service)
 	}
 
-	lcd.Reset()
-	lcd.Println(lcd.Line2, lcd.Center("started"))
+	lcd.Reset(display)
+	display.Println(lcd.Line2, lcd.Center("started"))
+
+	leds := NewLedNotifier(led, conf.ColorMap(), conf.patterns)
+	screen := NewLcdNotifier(display, conf.AuthorMap())
+	notifiers := []deploy.Notifier{leds, screen}
+	var webhooks []*chat.WebhookNotifier
+	for _, url := range conf.Chat.Webhooks {
+		webhook := chat.NewWebhookNotifier(url, conf.Chat.Username)
+		webhooks = append(webhooks, webhook)
+		notifiers = append(notifiers, webhook)
+	}
+	notifier := deploy.NewMultiNotifier(deploy.DefaultNotifyTimeout, notifiers...)
+
+	listener := deploy.NewChangeListener(notifier, router, state, conf.AlertDuration)
+	listener.EnableAudit(audit)
+	if conf.RollbackWindow > 0 {
+		listener.EnableRollback(router, time.Duration(conf.RollbackWindow)*time.Second)
+		for _, service := range conf.Services {
+			for _, promotion := range service.PromotionChain() {
+				listener.ResumeRollback(service.Name, service.Namespace, promotion)
+			}
+		}
+	}
+	freezes := deploy.NewFreezeCalendar(conf.freezeWindows)
+	listener.EnableFreezes(freezes)
+	for _, service := range conf.Services {
+		listener.RequireApprovals(service.Name, service.RequireApprovals)
+	}
+	if conf.Approvals.Button != "" {
+		startApprovalButton(ctx, listener, conf.Approvals.Button, conf.Approvals.ButtonName)
+	}
+	if conf.Rollout.Timeout > 0 {
+		listener.EnableRolloutTracking(router, time.Duration(conf.Rollout.Timeout)*time.Second, time.Duration(conf.Rollout.Interval)*time.Second)
+	} else {
+		log.Info("Rollout timeout is not set in config. Promotions are not tracked.")
+	}
+	actions := startActionChannel(ctx, listener)
+	listening := make(chan struct{})
+	go func() {
+		defer close(listening)
+		listener.Listen(ctx, watcher.Changes(), actions)
+	}()
 
-	notifier := NewLedNotifier(led, conf.ColorMap(), conf.AuthorMap())
+	configReloader := newReloader(source, conf, watcher, router, backends, leds, screen, freezes, listener)
+	reloadOnSignal(ctx, configReloader)
 
-	confirm := startConfirmChannel(ctx)
-	go deploy.ChangeListener(ctx, notifier, promoter, conf.AlertDuration, watcher.Changes(), confirm)
+	if conf.Api.Address != "" {
+		apiServer := api.NewServer(conf.Api.Address, conf.Api.Token, watcher, listener)
+		apiServer.EnableReload(configReloader)
+		if approvers := conf.ApproverMap(); len(approvers) > 0 {
+			apiServer.EnableApprovals(approvers)
+		}
+		if conf.Api.Webhook {
+			apiServer.EnableWebhook()
+		}
+		defer apiServer.Close()
+		go func() {
+			if err := apiServer.Listen(); err != nil {
+				log.Warnf("API server stopped: %v", err)
+			}
+		}()
+	} else {
+		log.Info("API address is not set in config. API server inactive.")
+	}
 
 	<-ctx.Done()
-	lcd.ClearAll()
+	// the notifiers can only be closed once nothing is left that notifies them.
+	watcher.Close()
+	<-listening
+	notifier.Close()
+	for _, webhook := range webhooks {
+		webhook.Close()
+	}
+	lcd.ClearAll(display)
 	log.Info("Done...")
 }
 
+// newDeployClient creates a release-manager client, overriding the default timeout and retry behaviour with whatever is
+// set in the config.
+func newDeployClient(conf *Config) *deploy.Client {
+	rm := conf.ReleaseManager
+	c := deploy.NewClient(rm.Url, rm.Token, rm.Caller)
+	if rm.Timeout > 0 {
+		c.HttpClient.Timeout = time.Duration(rm.Timeout) * time.Second
+	}
+	if rm.Retries > 0 {
+		c.Retries = rm.Retries
+	}
+	if rm.Retries < 0 {
+		c.Retries = 0
+	}
+	if rm.RetryBackoff > 0 {
+		c.Backoff = time.Duration(rm.RetryBackoff) * time.Millisecond
+	}
+	return c
+}
+
 // ContextWithCancelOnSignal creates a context that has an explicit cancel, as well as a cancel if a SIGTERM or SIGINT
 // is received by the application.
 func ContextWithCancelOnSignal() (context.Context, context.CancelFunc) {
@@ -180,113 +308,90 @@ func ContextWithCancelOnSignal() (context.Context, context.CancelFunc) {
 	return ctx, cancel
 }
 
-// startConfirmChannel creates a channel that will receive a boolean tick every time the button is pressed. The channel
-// will be closed when the context expires.
-func startConfirmChannel(ctx context.Context) <-chan bool {
-	confirm := make(chan bool)
+// startActionChannel creates a channel that will receive an action every time a button gesture is recognized. A short
+// press confirms, a long press skips and a double press confirms everything that is queued. When the release being
+// alerted about is frozen by a freeze that can be overridden, a long press overrides the freeze, and when nothing is
+// being alerted about, a long press asks for the last promotion to be rolled back instead. The channel will be closed
+// when the context expires.
+func startActionChannel(ctx context.Context, listener *deploy.ChangeListener) <-chan deploy.Action {
+	actions := make(chan deploy.Action)
 
 	go func() {
-		defer close(confirm)
-		events := button.InitButton()
+		defer close(actions)
+		gestures := button.RecognizeGestures(button.InitButton(), button.DefaultLongPress, button.DefaultDoublePress)
 
 		for {
 			select {
 			case <-ctx.Done():
-				break
-			case e := <-events:
-				log.Debugf("Event: %v", e)
-				if e.Pressed {
-					// non-blocking confirm. If nothing is listening to the tick, it will get lost.
-					select {
-					case confirm <- true:
-					default:
+				return
+			case g := <-gestures:
+				log.Debugf("Gesture: %v", g)
+				a := deploy.ActionConfirm
+				switch g {
+				case button.LongPress:
+					a = deploy.ActionSkip
+					current, alerting := listener.Current()
+					if !alerting {
+						a = deploy.ActionRollback
+					} else if current.Freeze != nil && current.Freeze.AllowOverride {
+						a = deploy.ActionOverride
 					}
+				case button.DoublePress:
+					a = deploy.ActionConfirmAll
+				}
+
+				// non-blocking action. If nothing is listening for actions, it will get lost.
+				select {
+				case actions <- a:
+				default:
 				}
 			}
 		}
 	}()
 
-	return confirm
+	return actions
 }
 
-// readConfig will open the config and return the parsed Config struct. If the config is encrypted, a small web server
-// will be spawned to take the passphrase as input in order to decrypt the config file on disk. The function will block
-// until a passphrase is input in this case.
-func readConfig(ctx context.Context, encrypted bool) (*Config, error) {
-	const configFile = "config.yaml"
-	const encryptedConfigFile = "config.yaml.enc"
+// startApprovalButton approves the release that is waiting for approvals every time the button on the given pin is
+// pressed, until the context expires.
+func startApprovalButton(ctx context.Context, listener *deploy.ChangeListener, pin, name string) {
+	events := button.InitButtonOnPin(pin)
 
-	if !encrypted {
-		log.Infof("Reading plain text config from: %v", configFile)
-		content, err := os.ReadFile(configFile)
-		if err != nil {
-			return nil, err
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e := <-events:
+				if e.Pressed && !listener.Approve(name) {
+					log.Debugf("Ignoring press of %v, nothing is waiting for approval.", name)
+				}
+			}
 		}
-		return parseConfig(content)
+	}()
+}
+
+// readConfig will open the config and return the parsed Config struct, together with the source that it was read from.
+// If the config is encrypted, a small web server will be spawned to take the passphrase as input in order to decrypt
+// the config file on disk. The function will block until a passphrase is input in this case.
+func readConfig(ctx context.Context, display lcd.Display, encrypted bool) (*Config, *configSource, error) {
+	source := &configSource{encrypted: encrypted}
+	if !encrypted {
+		conf, err := source.Read()
+		return conf, source, err
 	}
 
-	log.Infof("Reading encrypted config from: %v", encryptedConfigFile)
-	p := passphrase.NewServer()
+	p := passphrase.NewServer(display)
 	defer p.Close()
 
 	go p.Listen()
 
 	select {
 	case <-ctx.Done():
-		return nil, fmt.Errorf("context closing before passphrase received")
+		return nil, nil, fmt.Errorf("context closing before passphrase received")
 	case pass := <-p.PassChan():
-		fileContent, err := decryptFile(encryptedConfigFile, pass)
-		if err != nil {
-			return nil, fmt.Errorf("unable to decrypt config file: %w", err)
-		}
-		return parseConfig(fileContent)
-	}
-}
-
-type LedNotifier struct {
-	led       *neopixel.LedController
-	colorMap  map[string]uint32
-	authorMap map[string]string
-}
-
-func NewLedNotifier(l *neopixel.LedController, colorMap map[string]uint32, authorMap map[string]string) *LedNotifier {
-	return &LedNotifier{
-		led:       l,
-		colorMap:  colorMap,
-		authorMap: authorMap,
-	}
-}
-
-func (l *LedNotifier) Alert(service, author string) {
-	a := l.mapAuthor(author)
-	lcd.Print(service, a)
-
-	color, ok := l.colorMap[service]
-	if !ok {
-		color = 0x0000FF
-	}
-	l.led.Breathe(color)
-}
-
-func (l *LedNotifier) Success() {
-	l.led.Flash(neopixel.ColorGreen)
-}
-
-func (l *LedNotifier) Failure() {
-	l.led.Flash(neopixel.ColorRed)
-}
-
-func (l *LedNotifier) Reset() {
-	lcd.Reset()
-	l.led.Stop()
-}
-
-func (l *LedNotifier) mapAuthor(author string) string {
-	a, ok := l.authorMap[author]
-	if !ok {
-		// return the first word of the author string (first name?).
-		first, _, _ := strings.Cut(author, " ")
-		return first
+		source.passphrase = pass
+		conf, err := source.Read()
+		return conf, source, err
 	}
-	return a
 }
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/callebjorkell/big-switch/internal/deploy"
+	"github.com/callebjorkell/big-switch/internal/lcd"
+	"github.com/callebjorkell/big-switch/internal/neopixel"
+	"math"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// countdownInterval is how often the countdown on the LED ring is redrawn.
+const countdownInterval = 100 * time.Millisecond
+
+// LedNotifier shows alerts and their outcomes on the LED ring, in the color of the service. While a release is alerted
+// about, the ring counts down the time left to confirm it, unless an alert pattern has been configured.
+type LedNotifier struct {
+	led      *neopixel.LedController
+	lock     sync.Mutex
+	colorMap map[string]uint32
+	patterns ledPatterns
+	// countdown identifies the alert that the ring is counting down, so that refreshes of it do not restart the count.
+	countdown string
+}
+
+func NewLedNotifier(l *neopixel.LedController, colorMap map[string]uint32, patterns ledPatterns) *LedNotifier {
+	return &LedNotifier{
+		led:      l,
+		colorMap: colorMap,
+		patterns: patterns,
+	}
+}
+
+// Update replaces the service colors and patterns used for new alerts.
+func (l *LedNotifier) Update(colorMap map[string]uint32, patterns ledPatterns) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.colorMap = colorMap
+	l.patterns = patterns
+}
+
+// play the pattern configured for the event of the service, and returns false if there is none.
+func (l *LedNotifier) play(service, event string) bool {
+	l.lock.Lock()
+	pattern, ok := l.patterns.get(service, event)
+	l.lock.Unlock()
+
+	if ok {
+		l.led.Play(pattern)
+	}
+	return ok
+}
+
+// Alert drains the ring one LED at a time in the color of the service, until it is empty at the deadline.
+func (l *LedNotifier) Alert(e deploy.ChangeEvent, _ int, deadline time.Time) {
+	l.lock.Lock()
+	key := fmt.Sprintf("%v/%v/%v/%v", e.Service, e.Promotion, e.Artifact, deadline.UnixNano())
+	if l.countdown == key {
+		l.lock.Unlock()
+		return
+	}
+	l.countdown = key
+	pattern, hasPattern := l.patterns.get(e.Service, patternAlert)
+	color, ok := l.colorMap[e.Service]
+	l.lock.Unlock()
+
+	// the lock is not held while playing, since a pattern that does not repeat blocks until it is over.
+	if hasPattern {
+		l.led.Play(pattern)
+		return
+	}
+
+	if !ok {
+		color = 0x0000FF
+	}
+	total := time.Until(deadline)
+	l.led.Animate(countdownInterval, neopixel.Arc(color, func() float64 {
+		return float64(time.Until(deadline)) / float64(total)
+	}))
+}
+
+func (l *LedNotifier) Frozen(deploy.ChangeEvent, int, deploy.Freeze) {
+	l.stopCountdown()
+	l.led.Blink(neopixel.ColorIce)
+}
+
+func (l *LedNotifier) Approving(deploy.ChangeEvent, []string, int) {
+	l.stopCountdown()
+	l.led.Breathe(neopixel.ColorYellow)
+}
+
+// Confirmed replaces the countdown with a pulse for as long as the release is being promoted.
+func (l *LedNotifier) Confirmed(deploy.ChangeEvent) {
+	l.stopCountdown()
+	l.led.Pulse(neopixel.ColorYellow)
+}
+
+func (l *LedNotifier) TimedOut(deploy.ChangeEvent) {}
+
+func (l *LedNotifier) Deploying(deploy.ChangeEvent) {
+	l.stopCountdown()
+	l.led.Pulse(neopixel.ColorYellow)
+}
+
+func (l *LedNotifier) Rollback(deploy.ChangeEvent) {
+	l.stopCountdown()
+	l.led.Breathe(neopixel.ColorRed)
+}
+
+func (l *LedNotifier) Retrying(deploy.ChangeEvent, error, int) {}
+
+func (l *LedNotifier) Success(e deploy.ChangeEvent) {
+	l.stopCountdown()
+	if !l.play(e.Service, patternSuccess) {
+		l.led.Flash(neopixel.ColorGreen)
+	}
+}
+
+func (l *LedNotifier) Failure(e deploy.ChangeEvent, _ error) {
+	l.stopCountdown()
+	if !l.play(e.Service, patternFailure) {
+		l.led.Flash(neopixel.ColorRed)
+	}
+}
+
+func (l *LedNotifier) Reset() {
+	l.stopCountdown()
+	l.led.Stop()
+}
+
+// stopCountdown forgets the alert being counted down, so that the next alert starts a new count. The countdown itself
+// is stopped by whichever effect replaces it on the ring.
+func (l *LedNotifier) stopCountdown() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.countdown = ""
+}
+
+// LcdNotifier shows the service and author of alerts on the LCD, together with the seconds left to confirm them.
+type LcdNotifier struct {
+	display   lcd.Display
+	lock      sync.Mutex
+	authorMap map[string]string
+	// stop is closed to stop the countdown of the current alert.
+	stop chan struct{}
+}
+
+func NewLcdNotifier(display lcd.Display, authorMap map[string]string) *LcdNotifier {
+	return &LcdNotifier{
+		display:   display,
+		authorMap: authorMap,
+	}
+}
+
+// Update replaces the author aliases used for new alerts.
+func (l *LcdNotifier) Update(authorMap map[string]string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.authorMap = authorMap
+}
+
+// alertPage is one of the views that the second line of the LCD rotates between during an alert.
+type alertPage struct {
+	line     func(remaining time.Duration) string
+	duration time.Duration
+}
+
+// pageDuration is how long each page of an alert is shown, unless it needs longer to scroll through.
+const pageDuration = 4 * time.Second
+
+// Alert shows the service on the first line, while the second line pages between the author with the seconds left
+// until the deadline, the artifact, and a reminder to press the button. The second line is updated until the alert is
+// replaced by another notification.
+func (l *LcdNotifier) Alert(e deploy.ChangeEvent, pending int, deadline time.Time) {
+	a := l.mapAuthor(e.Author)
+	queue := ""
+	if pending > 1 {
+		queue = fmt.Sprintf(" 1/%d", pending)
+	}
+	pages := []alertPage{
+		{
+			line:     func(remaining time.Duration) string { return countdownLine(a, queue, remaining) },
+			duration: pageDuration,
+		},
+		{
+			line: func(time.Duration) string { return lcd.Center(e.Artifact) },
+			// give a long artifact the time to scroll through all of it.
+			duration: maxDuration(pageDuration, lcd.ScrollTime(l.display, e.Artifact)),
+		},
+		{
+			line:     func(time.Duration) string { return lcd.Center("press to deploy") },
+			duration: pageDuration,
+		},
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.stopCountdown()
+	l.display.Println(lcd.Line1, lcd.Center(e.Service))
+	l.display.Println(lcd.Line2, pages[0].line(time.Until(deadline)))
+
+	stop := make(chan struct{})
+	l.stop = stop
+	go func() {
+		tick := time.NewTicker(time.Second)
+		defer tick.Stop()
+
+		page, shown := 0, time.Now()
+		for time.Now().Before(deadline) {
+			select {
+			case <-stop:
+				return
+			case <-tick.C:
+			}
+
+			if time.Since(shown) >= pages[page].duration {
+				page, shown = (page+1)%len(pages), time.Now()
+			}
+
+			l.lock.Lock()
+			select {
+			case <-stop:
+			default:
+				l.display.Println(lcd.Line2, pages[page].line(time.Until(deadline)))
+			}
+			l.lock.Unlock()
+		}
+	}()
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// countdownLine shows the author followed by the queue position, with the remaining seconds at the end of the line. The
+// author is cut short if everything does not fit on the line. Lengths are counted in runes, since every rune is a
+// single character on the LCD.
+func countdownLine(author, queue string, remaining time.Duration) string {
+	seconds := fmt.Sprintf("%ds", int(math.Max(math.Ceil(remaining.Seconds()), 0)))
+	name := []rune(author)
+	if room := 16 - len(queue) - len(seconds) - 1; len(name) > room {
+		name = name[:room]
+	}
+	text := string(name) + queue
+	return text + strings.Repeat(" ", 16-utf8.RuneCountInString(text)-len(seconds)) + seconds
+}
+
+// stopCountdown stops the countdown of the current alert. The lock must be held.
+func (l *LcdNotifier) stopCountdown() {
+	if l.stop != nil {
+		close(l.stop)
+		l.stop = nil
+	}
+}
+
+// print stops any countdown, and shows the lines centered on the LCD.
+func (l *LcdNotifier) print(line1, line2 string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.stopCountdown()
+	lcd.Print(l.display, line1, line2)
+}
+
+func (l *LcdNotifier) Frozen(e deploy.ChangeEvent, pending int, _ deploy.Freeze) {
+	status := "FROZEN"
+	if pending > 1 {
+		status += fmt.Sprintf(" 1/%d", pending)
+	}
+	l.print(e.Service, status)
+}
+
+func (l *LcdNotifier) Approving(e deploy.ChangeEvent, approvers []string, required int) {
+	l.print(e.Service, fmt.Sprintf("approved %d/%d", len(approvers), required))
+}
+
+// Confirmed stops the countdown, and shows that the release is being promoted until the outcome is known.
+func (l *LcdNotifier) Confirmed(e deploy.ChangeEvent) {
+	l.print(e.Service, "deploying")
+}
+
+func (l *LcdNotifier) TimedOut(deploy.ChangeEvent) {}
+
+func (l *LcdNotifier) Deploying(e deploy.ChangeEvent) {
+	l.print(e.Service, "deploying")
+}
+
+func (l *LcdNotifier) Rollback(e deploy.ChangeEvent) {
+	l.print("ROLLBACK?", e.Service)
+}
+
+func (l *LcdNotifier) Success(deploy.ChangeEvent) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.stopCountdown()
+}
+
+func (l *LcdNotifier) Retrying(e deploy.ChangeEvent, err error, _ int) {
+	l.print(e.Service, fmt.Sprintf("%v retrying", deploy.ErrorCode(err)))
+}
+
+// Failure shows what failed on the first line, and a summary of the error on the second.
+func (l *LcdNotifier) Failure(_ deploy.ChangeEvent, err error) {
+	title := "TRIGGER FAILED"
+	var releaseErr *deploy.ReleaseError
+	if errors.As(err, &releaseErr) {
+		if releaseErr.Rollout {
+			title = "ROLLOUT FAILED"
+		} else if releaseErr.Rollback {
+			title = "ROLLBACK FAILED"
+		}
+	}
+	l.print(title, deploy.ErrorSummary(err))
+}
+
+func (l *LcdNotifier) Reset() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.stopCountdown()
+	lcd.Reset(l.display)
+}
+
+func (l *LcdNotifier) mapAuthor(author string) string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	a, ok := l.authorMap[author]
+	if !ok {
+		// return the first word of the author string (first name?).
+		first, _, _ := strings.Cut(author, " ")
+		return first
+	}
+	return a
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"github.com/callebjorkell/big-switch/internal/deploy"
+	"github.com/callebjorkell/big-switch/internal/lcd"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLcdNotifier_ConfirmedStopsCountdown(t *testing.T) {
+	display := lcd.NewRecorder()
+	n := NewLcdNotifier(display, nil)
+
+	e := deploy.ChangeEvent{Service: "some-service", Artifact: "some-artifact", Author: "Some Author"}
+	n.Alert(e, 1, time.Now().Add(10*time.Second))
+	assert.Eventually(t, func() bool {
+		return len(display.History()) > 2
+	}, 2*time.Second, 10*time.Millisecond, "the countdown should update the LCD")
+
+	n.Confirmed(e)
+	confirmed := display.History()
+	time.Sleep(1500 * time.Millisecond)
+
+	assert.Equal(t, confirmed, display.History(), "nothing should be written to the LCD after the confirmation")
+	assert.Equal(t, lcd.Center("some-service"), display.Line(lcd.Line1))
+	assert.Equal(t, lcd.Center("deploying"), display.Line(lcd.Line2))
+}
+
+func TestCountdownLine(t *testing.T) {
+	assert.Equal(t, "Some 1/2     42s", countdownLine("Some", " 1/2", 42*time.Second))
+	// the author is cut short by characters, not bytes, so that no character is cut in half.
+	assert.Equal(t, "Björn-Åke And 9s", countdownLine("Björn-Åke Andersson", "", 9*time.Second))
+}
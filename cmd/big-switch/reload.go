@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/callebjorkell/big-switch/internal/deploy"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	configFile          = "config.yaml"
+	encryptedConfigFile = "config.yaml.enc"
+)
+
+// configSource reads the config from disk. The passphrase of an encrypted config is kept in memory, so that the config
+// can be read again without asking for the passphrase.
+type configSource struct {
+	encrypted  bool
+	passphrase string
+}
+
+func (s *configSource) Read() (*Config, error) {
+	if !s.encrypted {
+		log.Infof("Reading plain text config from: %v", configFile)
+		content, err := os.ReadFile(configFile)
+		if err != nil {
+			return nil, err
+		}
+		return parseConfig(content)
+	}
+
+	log.Infof("Reading encrypted config from: %v", encryptedConfigFile)
+	fileContent, err := decryptFile(encryptedConfigFile, s.passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt config file: %w", err)
+	}
+	return parseConfig(fileContent)
+}
+
+// reloader re-reads the config, and applies the changes to the running watcher and notifiers. Only the watched
+// services, their colors and LED patterns, the author aliases, the freeze windows and the required approvals are
+// reloaded. Services can be moved between the backends that were created at startup, but any other change, including
+// declaring new backends, needs a restart to take effect.
+type reloader struct {
+	lock     sync.Mutex
+	source   *configSource
+	conf     *Config
+	watcher  *deploy.Watcher
+	router   *deploy.Router
+	backends *backendSet
+	leds     *LedNotifier
+	screen   *LcdNotifier
+	freezes  *deploy.FreezeCalendar
+	listener *deploy.ChangeListener
+}
+
+func newReloader(source *configSource, conf *Config, watcher *deploy.Watcher, router *deploy.Router, backends *backendSet, leds *LedNotifier, screen *LcdNotifier, freezes *deploy.FreezeCalendar, listener *deploy.ChangeListener) *reloader {
+	return &reloader{
+		source:   source,
+		conf:     conf,
+		watcher:  watcher,
+		router:   router,
+		backends: backends,
+		leds:     leds,
+		screen:   screen,
+		freezes:  freezes,
+		listener: listener,
+	}
+}
+
+func (r *reloader) Reload() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	log.Info("Reloading config.")
+	conf, err := r.source.Read()
+	if err != nil {
+		return fmt.Errorf("unable to reload config: %w", err)
+	}
+
+	current := make(map[string]ServiceConfig)
+	for _, service := range r.conf.Services {
+		current[service.Name] = service
+	}
+
+	for _, service := range conf.Services {
+		r.listener.RequireApprovals(service.Name, service.RequireApprovals)
+		old, ok := current[service.Name]
+		delete(current, service.Name)
+		if ok && old.WatchEquals(service) {
+			continue
+		}
+		if ok {
+			log.Infof("Watch of %v changed. Restarting it.", service.Name)
+			if err := r.watcher.RemoveWatch(service.Name); err != nil {
+				log.Warnf("Unable to remove watch of %v: %v", service.Name, err)
+			}
+		}
+		if err := r.backends.route(r.router, service); err != nil {
+			log.Warn(err)
+			continue
+		}
+		addWatches(r.watcher, service)
+	}
+
+	for name := range current {
+		r.listener.RequireApprovals(name, 0)
+		log.Infof("Service %v removed from config. Stopping watch.", name)
+		if err := r.watcher.RemoveWatch(name); err != nil {
+			log.Warnf("Unable to remove watch of %v: %v", name, err)
+		}
+	}
+
+	r.leds.Update(conf.ColorMap(), conf.patterns)
+	r.screen.Update(conf.AuthorMap())
+	r.freezes.SetWindows(conf.freezeWindows)
+	r.conf = conf
+	log.Info("Config reloaded.")
+	return nil
+}
+
+// reloadOnSignal reloads the config every time a USR1 signal is received, until the context expires.
+func reloadOnSignal(ctx context.Context, r *reloader) {
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, syscall.SIGUSR1)
+
+	go func() {
+		defer signal.Stop(signalChan)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signalChan:
+				if err := r.Reload(); err != nil {
+					log.Warn(err)
+				}
+			}
+		}
+	}()
+}
+
+// addWatches starts watching every promotion of the service.
+func addWatches(watcher *deploy.Watcher, service ServiceConfig) {
+	pollingInterval := time.Duration(service.PollingInterval) * time.Second
+	warmupDuration := time.Duration(service.WarmupDuration) * time.Second
+	for _, promotion := range service.PromotionChain() {
+		_, err := watcher.AddWatch(service.Name, service.Namespace, promotion, pollingInterval, warmupDuration)
+		if err != nil {
+			log.Warnf("Unable to watch %v: %v", service.Name, err)
+		}
+	}
+}
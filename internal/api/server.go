@@ -0,0 +1,440 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/callebjorkell/big-switch/internal/deploy"
+	"github.com/callebjorkell/big-switch/internal/metrics"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Server is a long-lived HTTP API for checking the status of, and controlling, a running big-switch.
+type Server struct {
+	server   http.Server
+	mux      *http.ServeMux
+	token    string
+	watcher  *deploy.Watcher
+	listener *deploy.ChangeListener
+	// approvers maps the names of approvers to the secrets that they sign approvals with.
+	approvers map[string]string
+}
+
+// NewServer creates an API server on the given address. If token is set, every request has to carry it as a bearer
+// token. A server without a token can only listen on a loopback address.
+func NewServer(addr, token string, watcher *deploy.Watcher, listener *deploy.ChangeListener) *Server {
+	s := &Server{
+		mux:      http.NewServeMux(),
+		token:    token,
+		watcher:  watcher,
+		listener: listener,
+	}
+	s.mux.HandleFunc("/services", s.servicesHandler)
+	s.mux.HandleFunc("/services/", s.serviceHandler)
+	s.mux.HandleFunc("/alert", s.alertHandler)
+	s.mux.HandleFunc("/alert/confirm", s.actionHandler(deploy.ActionConfirm))
+	s.mux.HandleFunc("/alert/skip", s.actionHandler(deploy.ActionSkip))
+	s.mux.HandleFunc("/rollback", s.rollbackHandler)
+	s.mux.Handle("/metrics", metrics.Handler())
+
+	s.server = http.Server{
+		Addr:    addr,
+		Handler: s.authenticated(s.mux),
+	}
+	return s
+}
+
+// Reloader reloads the configuration of the running server.
+type Reloader interface {
+	Reload() error
+}
+
+// EnableReload makes the configuration reloadable through a POST to /reload.
+func (s *Server) EnableReload(r Reloader) {
+	s.mux.HandleFunc("/reload", func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		log.Info("Reloading config through the API.")
+		if err := r.Reload(); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// EnableApprovals makes the server accept signed approvals of releases through a POST to /alert/approve. The approvers
+// map the name of every approver to the secret that they sign their approvals with.
+func (s *Server) EnableApprovals(approvers map[string]string) {
+	s.approvers = approvers
+	s.mux.HandleFunc("/alert/approve", s.approveHandler)
+}
+
+// EnableWebhook makes the server accept release notifications through a POST to /webhook. A notification makes the
+// watches of the service check for new releases right away, without waiting for the next polling interval.
+func (s *Server) EnableWebhook() {
+	s.mux.HandleFunc("/webhook", s.webhookHandler)
+}
+
+// Listen serves the API until the server is closed. An error is returned right away if the server has no token and the
+// address is reachable from other hosts, since anyone could then promote releases through it.
+func (s *Server) Listen() error {
+	if s.token == "" && !isLoopback(s.server.Addr) {
+		return fmt.Errorf("refusing to serve the API on %v without a token", s.server.Addr)
+	}
+
+	log.Infof("Starting API server on %v.", s.server.Addr)
+	err := s.server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+func (s *Server) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	log.Debug("Closing API server...")
+	return s.server.Shutdown(ctx)
+}
+
+// isLoopback returns whether the address only listens on the loopback interface.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (s *Server) authenticated(next http.Handler) http.Handler {
+	expected := []byte(fmt.Sprintf("Bearer %v", s.token))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given := []byte(r.Header.Get("Authorization"))
+		if s.token != "" && subtle.ConstantTimeCompare(given, expected) != 1 {
+			writeError(w, http.StatusUnauthorized, "missing or invalid token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type artifact struct {
+	Name   string    `json:"name"`
+	Author string    `json:"author,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+type service struct {
+	Name        string     `json:"name"`
+	Namespace   string     `json:"namespace,omitempty"`
+	From        string     `json:"from"`
+	To          string     `json:"to"`
+	Paused      bool       `json:"paused"`
+	Source      *artifact  `json:"source,omitempty"`
+	Target      *artifact  `json:"target,omitempty"`
+	LastChecked *time.Time `json:"lastChecked,omitempty"`
+}
+
+type release struct {
+	Service  string `json:"service"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Artifact string `json:"artifact"`
+	Author   string `json:"author"`
+}
+
+type alert struct {
+	release
+	Started  time.Time `json:"started"`
+	Deadline time.Time `json:"deadline"`
+	Rollback bool      `json:"rollback,omitempty"`
+	// Frozen is the name of the freeze window that stops the release from being promoted.
+	Frozen string `json:"frozen,omitempty"`
+	// Nonce is unique to the alert, and is part of the signature of approvals, so that they cannot be replayed.
+	Nonce string `json:"nonce"`
+}
+
+type alertStatus struct {
+	Current *alert    `json:"current"`
+	Queued  []release `json:"queued"`
+}
+
+func (s *Server) servicesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	services := make([]service, 0)
+	for _, status := range s.watcher.Watches() {
+		services = append(services, toService(status))
+	}
+	writeJSON(w, http.StatusOK, services)
+}
+
+// serviceHandler handles the pause and resume requests for a single service at /services/<name>/pause and
+// /services/<name>/resume.
+func (s *Server) serviceHandler(w http.ResponseWriter, r *http.Request) {
+	name, action, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/services/"), "/")
+	if !ok || name == "" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var err error
+	switch action {
+	case "pause":
+		log.Infof("Pausing watch of %v through the API.", name)
+		err = s.watcher.PauseWatch(name)
+	case "resume":
+		log.Infof("Resuming watch of %v through the API.", name)
+		err = s.watcher.ResumeWatch(name)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if errors.Is(err, deploy.ErrNotWatched) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) alertHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	status := alertStatus{
+		Queued: make([]release, 0),
+	}
+	if current, ok := s.listener.Current(); ok {
+		status.Current = &alert{
+			release:  toRelease(current.ChangeEvent),
+			Started:  current.Started,
+			Deadline: current.Deadline,
+			Rollback: current.Rollback,
+			Nonce:    nonce(current),
+		}
+		if current.Freeze != nil {
+			status.Current.Frozen = current.Freeze.Name
+		}
+	}
+	for _, e := range s.listener.Queue().Pending() {
+		status.Queued = append(status.Queued, toRelease(e))
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (s *Server) actionHandler(a deploy.Action) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		log.Infof("Received %v through the API.", a)
+		if current, ok := s.listener.Current(); ok && current.Freeze != nil && a != deploy.ActionSkip {
+			writeError(w, http.StatusConflict, fmt.Sprintf("promotions are frozen by %v", current.Freeze.Name))
+			return
+		}
+		if !s.listener.Act(a) {
+			writeError(w, http.StatusConflict, "no release is currently being alerted about")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// rollbackHandler asks for the last promotion to be rolled back. Like with the button, the rollback is only made once it
+// has been confirmed through /alert/confirm.
+func (s *Server) rollbackHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, ok := s.listener.RollbackCandidate(); !ok {
+		writeError(w, http.StatusConflict, "there is no promotion to roll back")
+		return
+	}
+	log.Info("Received rollback request through the API.")
+	if !s.listener.RequestRollback() {
+		writeError(w, http.StatusConflict, "a release is currently being alerted about or promoted")
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+type approval struct {
+	Approver string `json:"approver"`
+	// Signature is the hex encoded HMAC-SHA256 of "<service>/<artifact>/<nonce>", using the secret of the approver as key.
+	Signature string `json:"signature"`
+}
+
+func (s *Server) approveHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	a := approval{}
+	if err := json.NewDecoder(r.Body).Decode(&a); err != nil || a.Approver == "" {
+		writeError(w, http.StatusBadRequest, "body must be a JSON object with an approver and a signature")
+		return
+	}
+
+	current, ok := s.listener.Current()
+	if !ok {
+		writeError(w, http.StatusConflict, "no release is currently being alerted about")
+		return
+	}
+
+	secret, ok := s.approvers[a.Approver]
+	if !ok || !validSignature(secret, message(current.Service, current.Artifact, nonce(current)), a.Signature) {
+		log.Warnf("Rejected approval of %v by %v with an invalid signature.", current.Service, a.Approver)
+		writeError(w, http.StatusForbidden, "unknown approver or invalid signature")
+		return
+	}
+
+	log.Infof("Received approval of %v by %v through the API.", current.Service, a.Approver)
+	if !s.listener.Approve(a.Approver) {
+		writeError(w, http.StatusConflict, "the release is not waiting for approvals")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Sign gives the signature of an approval of the artifact of the service, for the alert with the given nonce.
+func Sign(secret, service, artifact, nonce string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(message(service, artifact, nonce)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func message(service, artifact, nonce string) string {
+	return service + "/" + artifact + "/" + nonce
+}
+
+// nonce identifies the alert by when it started, which is different for every alert of the same release.
+func nonce(a deploy.Alert) string {
+	return strconv.FormatInt(a.Started.UnixNano(), 10)
+}
+
+func validSignature(secret, message, signature string) bool {
+	given, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(message))
+	return hmac.Equal(given, mac.Sum(nil))
+}
+
+type releaseNotification struct {
+	Service     string `json:"service"`
+	Environment string `json:"environment"`
+	Artifact    string `json:"artifact"`
+}
+
+func (s *Server) webhookHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	notification := releaseNotification{}
+	if err := json.NewDecoder(r.Body).Decode(&notification); err != nil || notification.Service == "" {
+		writeError(w, http.StatusBadRequest, "body must be a JSON object with at least a service")
+		return
+	}
+
+	log.Infof("Received release notification of %v (%v) in %v.", notification.Service, notification.Artifact, notification.Environment)
+	err := s.watcher.RefreshWatch(notification.Service, notification.Environment)
+	if errors.Is(err, deploy.ErrNotWatched) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func toService(status deploy.WatchStatus) service {
+	s := service{
+		Name:      status.Service,
+		Namespace: status.Namespace,
+		From:      status.Promotion.From,
+		To:        status.Promotion.To,
+		Paused:    status.Paused,
+	}
+	if !status.LastChecked.IsZero() {
+		s.LastChecked = &status.LastChecked
+		s.Source = toArtifact(status.Artifacts.Source)
+		s.Target = toArtifact(status.Artifacts.Target)
+	}
+	return s
+}
+
+func toArtifact(a deploy.Artifact) *artifact {
+	if a.Name == "" {
+		return nil
+	}
+	return &artifact{
+		Name:   a.Name,
+		Author: a.Author,
+		Time:   time.UnixMilli(a.Time),
+	}
+}
+
+func toRelease(e deploy.ChangeEvent) release {
+	return release{
+		Service:  e.Service,
+		From:     e.Promotion.From,
+		To:       e.Promotion.To,
+		Artifact: e.Artifact,
+		Author:   e.Author,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Warnf("Unable to write API response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
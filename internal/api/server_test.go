@@ -0,0 +1,238 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/callebjorkell/big-switch/internal/deploy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServices(t *testing.T) {
+	s, _ := newTestServer(t, "")
+
+	resp := do(t, s, http.MethodGet, "/services", "")
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	var services []service
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&services))
+	require.Len(t, services, 1)
+	assert.Equal(t, "some-service", services[0].Name)
+	assert.Equal(t, "dev", services[0].From)
+	assert.Equal(t, "prod", services[0].To)
+	assert.False(t, services[0].Paused)
+}
+
+func TestPauseAndResume(t *testing.T) {
+	s, _ := newTestServer(t, "")
+
+	resp := do(t, s, http.MethodPost, "/services/some-service/pause", "")
+	require.Equal(t, http.StatusNoContent, resp.Code)
+	assert.True(t, s.watcher.Watches()[0].Paused)
+
+	resp = do(t, s, http.MethodPost, "/services/some-service/resume", "")
+	require.Equal(t, http.StatusNoContent, resp.Code)
+	assert.False(t, s.watcher.Watches()[0].Paused)
+
+	resp = do(t, s, http.MethodPost, "/services/other-service/pause", "")
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestAlertAndConfirm(t *testing.T) {
+	s, changes := newTestServer(t, "")
+
+	resp := do(t, s, http.MethodPost, "/alert/confirm", "")
+	assert.Equal(t, http.StatusConflict, resp.Code)
+
+	changes <- deploy.ChangeEvent{Service: "some-service", Promotion: deploy.DefaultPromotion, Artifact: "some-artifact"}
+	require.Eventually(t, func() bool {
+		_, ok := s.listener.Current()
+		return ok
+	}, time.Second, 5*time.Millisecond)
+
+	resp = do(t, s, http.MethodGet, "/alert", "")
+	require.Equal(t, http.StatusOK, resp.Code)
+	var status alertStatus
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&status))
+	require.NotNil(t, status.Current)
+	assert.Equal(t, "some-artifact", status.Current.Artifact)
+	assert.Empty(t, status.Queued)
+
+	resp = do(t, s, http.MethodPost, "/alert/skip", "")
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+}
+
+func TestRollback(t *testing.T) {
+	s, _ := newTestServer(t, "")
+
+	resp := do(t, s, http.MethodPost, "/rollback", "")
+	assert.Equal(t, http.StatusConflict, resp.Code)
+}
+
+func TestApprove(t *testing.T) {
+	s, changes := newTestServer(t, "")
+	s.EnableApprovals(map[string]string{"alice": "alice-secret"})
+	s.listener.RequireApprovals("some-service", 2)
+
+	changes <- deploy.ChangeEvent{Service: "some-service", Promotion: deploy.DefaultPromotion, Artifact: "some-artifact"}
+	require.Eventually(t, func() bool {
+		_, ok := s.listener.Current()
+		return ok
+	}, time.Second, 5*time.Millisecond)
+
+	resp := do(t, s, http.MethodGet, "/alert", "")
+	require.Equal(t, http.StatusOK, resp.Code)
+	status := alertStatus{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&status))
+	require.NotNil(t, status.Current)
+	require.NotEmpty(t, status.Current.Nonce)
+
+	body := `{"approver":"alice","signature":"` + Sign("alice-secret", "some-service", "some-artifact", status.Current.Nonce) + `"}`
+	resp = doWithBody(t, s, "/alert/approve", body)
+	assert.Equal(t, http.StatusConflict, resp.Code, "approvals are only accepted after the release has been confirmed")
+
+	require.True(t, s.listener.Act(deploy.ActionConfirm))
+
+	resp = doWithBody(t, s, "/alert/approve", `{"approver":"alice","signature":"`+Sign("wrong-secret", "some-service", "some-artifact", status.Current.Nonce)+`"}`)
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+
+	// a signature of an earlier alert of the same release cannot be replayed.
+	resp = doWithBody(t, s, "/alert/approve", `{"approver":"alice","signature":"`+Sign("alice-secret", "some-service", "some-artifact", "1")+`"}`)
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+
+	require.Eventually(t, func() bool {
+		return doWithBody(t, s, "/alert/approve", body).Code == http.StatusNoContent
+	}, time.Second, 5*time.Millisecond)
+
+	// a confirmation through the API is not an approval, so the release still needs a second approval.
+	time.Sleep(20 * time.Millisecond)
+	_, ok := s.listener.Current()
+	assert.True(t, ok)
+}
+
+func TestMetrics(t *testing.T) {
+	s, _ := newTestServer(t, "")
+
+	resp := do(t, s, http.MethodGet, "/metrics", "")
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), "go_goroutines")
+}
+
+func TestReload(t *testing.T) {
+	s, _ := newTestServer(t, "")
+
+	resp := do(t, s, http.MethodPost, "/reload", "")
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	r := &reloaderMock{}
+	s.EnableReload(r)
+	resp = do(t, s, http.MethodPost, "/reload", "")
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+	assert.Equal(t, 1, r.reloads)
+}
+
+func TestWebhook(t *testing.T) {
+	s, _ := newTestServer(t, "")
+	s.EnableWebhook()
+
+	resp := doWithBody(t, s, "/webhook", `{"service":"some-service","environment":"dev","artifact":"some-artifact"}`)
+	assert.Equal(t, http.StatusAccepted, resp.Code)
+
+	resp = doWithBody(t, s, "/webhook", `{"service":"other-service","environment":"dev"}`)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	resp = doWithBody(t, s, "/webhook", `not json`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+}
+
+func TestToken(t *testing.T) {
+	s, _ := newTestServer(t, "secret")
+
+	resp := do(t, s, http.MethodGet, "/services", "")
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+
+	resp = do(t, s, http.MethodGet, "/services", "secret")
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	resp = do(t, s, http.MethodGet, "/services", "secre")
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+}
+
+func TestListenRequiresToken(t *testing.T) {
+	s, _ := newTestServer(t, "")
+	assert.Error(t, s.Listen())
+
+	assert.True(t, isLoopback("127.0.0.1:8080"))
+	assert.True(t, isLoopback("localhost:8080"))
+	assert.True(t, isLoopback("[::1]:8080"))
+	assert.False(t, isLoopback(":8080"))
+	assert.False(t, isLoopback("0.0.0.0:8080"))
+}
+
+func newTestServer(t *testing.T, token string) (*Server, chan<- deploy.ChangeEvent) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	watcher := deploy.NewWatcher(deploy.NewClient("http://localhost", "token", "me@local.com"), deploy.NewStateStore())
+	t.Cleanup(func() { watcher.Close() })
+	_, err := watcher.AddWatch("some-service", "", deploy.DefaultPromotion, time.Hour, time.Hour)
+	require.NoError(t, err)
+
+	changes := make(chan deploy.ChangeEvent)
+	listener := deploy.NewChangeListener(nopNotifier{}, nopDeployer{}, deploy.NewStateStore(), 45)
+	go listener.Listen(ctx, changes, nil)
+
+	return NewServer(":0", token, watcher, listener), changes
+}
+
+func do(t *testing.T, s *Server, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp := httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(resp, req)
+	return resp
+}
+
+func doWithBody(t *testing.T, s *Server, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(resp, req)
+	return resp
+}
+
+type nopNotifier struct{}
+
+func (nopNotifier) Alert(deploy.ChangeEvent, int, time.Time)      {}
+func (nopNotifier) Frozen(deploy.ChangeEvent, int, deploy.Freeze) {}
+func (nopNotifier) Approving(deploy.ChangeEvent, []string, int)   {}
+func (nopNotifier) Deploying(deploy.ChangeEvent)                  {}
+func (nopNotifier) Rollback(deploy.ChangeEvent)                   {}
+func (nopNotifier) Confirmed(deploy.ChangeEvent)                  {}
+func (nopNotifier) TimedOut(deploy.ChangeEvent)                   {}
+func (nopNotifier) Retrying(deploy.ChangeEvent, error, int)       {}
+func (nopNotifier) Success(deploy.ChangeEvent)                    {}
+func (nopNotifier) Failure(deploy.ChangeEvent, error)             {}
+func (nopNotifier) Reset()                                        {}
+
+type nopDeployer struct{}
+
+func (nopDeployer) Promote(context.Context, deploy.ChangeEvent) error {
+	return nil
+}
+
+type reloaderMock struct {
+	reloads int
+}
+
+func (r *reloaderMock) Reload() error {
+	r.reloads++
+	return nil
+}
@@ -11,8 +11,16 @@ import (
 
 // InitButton initializes all the button pins and fetches a button event channel
 func InitButton() <-chan Event {
-	log.Infoln("Initializing button handler")
-	button := gpioreg.ByName("GPIO20")
+	return InitButtonOnPin(DefaultPin)
+}
+
+// InitButtonOnPin initializes a button connected to the given pin, like "GPIO21", and fetches its event channel.
+func InitButtonOnPin(pin string) <-chan Event {
+	log.Infof("Initializing button handler on %v", pin)
+	button := gpioreg.ByName(pin)
+	if button == nil {
+		log.Fatalf("No such pin: %v", pin)
+	}
 
 	c := make(chan Event, 5)
 	go handleButton(button, c)
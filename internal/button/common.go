@@ -2,6 +2,9 @@ package button
 
 import "fmt"
 
+// DefaultPin is the pin that the big switch is connected to.
+const DefaultPin = "GPIO20"
+
 type Event struct {
 	Pressed bool
 }
@@ -0,0 +1,106 @@
+package button
+
+import (
+	"time"
+)
+
+type Gesture int
+
+const (
+	ShortPress Gesture = iota
+	LongPress
+	DoublePress
+)
+
+const (
+	// DefaultLongPress is how long the button needs to be held down for a press to count as a long press.
+	DefaultLongPress = 2 * time.Second
+	// DefaultDoublePress is the longest time between releasing the button and pressing it again for the two presses to
+	// count as a double press.
+	DefaultDoublePress = 400 * time.Millisecond
+)
+
+func (g Gesture) String() string {
+	switch g {
+	case ShortPress:
+		return "short press"
+	case LongPress:
+		return "long press"
+	case DoublePress:
+		return "double press"
+	}
+	return "unknown gesture"
+}
+
+type gestureState int
+
+const (
+	idle gestureState = iota
+	pressed
+	released
+	held
+)
+
+// RecognizeGestures turns the raw button events into gestures. A long press is reported as soon as the button has been
+// held down for longPress, and a double press as soon as the button is pressed the second time within doublePress of
+// being released. A short press is only reported once the double press window has passed. The returned channel is
+// closed when the event channel is closed.
+func RecognizeGestures(events <-chan Event, longPress, doublePress time.Duration) <-chan Gesture {
+	gestures := make(chan Gesture, 5)
+
+	go func() {
+		defer close(gestures)
+
+		state := idle
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		startTimer := func(d time.Duration) {
+			stopTimer(timer)
+			timer = time.NewTimer(d)
+			timeout = timer.C
+		}
+		defer func() { stopTimer(timer) }()
+
+		for {
+			select {
+			case e, ok := <-events:
+				if !ok {
+					return
+				}
+
+				switch {
+				case e.Pressed && state == idle:
+					state = pressed
+					startTimer(longPress)
+				case e.Pressed && state == released:
+					state = held
+					timeout = nil
+					gestures <- DoublePress
+				case !e.Pressed && state == pressed:
+					state = released
+					startTimer(doublePress)
+				case !e.Pressed && state == held:
+					state = idle
+				}
+			case <-timeout:
+				timeout = nil
+				switch state {
+				case pressed:
+					state = held
+					gestures <- LongPress
+				case released:
+					state = idle
+					gestures <- ShortPress
+				}
+			}
+		}
+	}()
+
+	return gestures
+}
+
+func stopTimer(t *time.Timer) {
+	if t != nil {
+		t.Stop()
+	}
+}
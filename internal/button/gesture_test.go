@@ -0,0 +1,82 @@
+package button
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+const (
+	testLongPress   = 50 * time.Millisecond
+	testDoublePress = 20 * time.Millisecond
+)
+
+func TestRecognizeGestures(t *testing.T) {
+	tt := []struct {
+		name     string
+		presses  []time.Duration
+		expected Gesture
+	}{
+		{
+			"short press",
+			[]time.Duration{time.Millisecond},
+			ShortPress,
+		},
+		{
+			"long press",
+			[]time.Duration{testLongPress + 20*time.Millisecond},
+			LongPress,
+		},
+		{
+			"double press",
+			[]time.Duration{time.Millisecond, time.Millisecond},
+			DoublePress,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			events := make(chan Event)
+			gestures := RecognizeGestures(events, testLongPress, testDoublePress)
+
+			for _, hold := range tc.presses {
+				events <- Event{Pressed: true}
+				<-time.After(hold)
+				events <- Event{Pressed: false}
+			}
+
+			select {
+			case g := <-gestures:
+				assert.Equal(t, tc.expected, g)
+			case <-time.After(250 * time.Millisecond):
+				t.Fatal("timed out waiting for gesture")
+			}
+
+			close(events)
+			for g := range gestures {
+				t.Fatalf("unexpected extra gesture: %v", g)
+			}
+		})
+	}
+}
+
+func TestRecognizeGestures_SeparatePresses(t *testing.T) {
+	events := make(chan Event)
+	gestures := RecognizeGestures(events, testLongPress, testDoublePress)
+	defer close(events)
+
+	for i := 0; i < 2; i++ {
+		events <- Event{Pressed: true}
+		events <- Event{Pressed: false}
+		<-time.After(2 * testDoublePress)
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case g := <-gestures:
+			assert.Equal(t, ShortPress, g)
+		case <-time.After(250 * time.Millisecond):
+			t.Fatal("timed out waiting for gesture")
+		}
+	}
+}
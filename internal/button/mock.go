@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
 // InitButton initializes all the button pins and fetches a button event channel
@@ -18,15 +19,32 @@ func InitButton() <-chan Event {
 	return c
 }
 
+// InitButtonOnPin fetches the event channel of a button connected to the given pin. Only the button on DefaultPin is
+// simulated, and the channel of any other pin never receives an event.
+func InitButtonOnPin(pin string) <-chan Event {
+	if pin == DefaultPin {
+		return InitButton()
+	}
+	log.Infof("Button on %v is not simulated", pin)
+	return make(chan Event)
+}
+
+// simulateButton turns a HUP signal into a short press of the button, and a USR2 signal into a long press.
 func simulateButton(c chan<- Event) {
-	hupChan := make(chan os.Signal, 1)
-	signal.Notify(hupChan, syscall.SIGHUP)
-	defer close(hupChan)
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGHUP, syscall.SIGUSR2)
+	defer close(sigChan)
 
 	for {
-		<-hupChan
+		sig := <-sigChan
 		c <- Event{
 			Pressed: true,
 		}
+		if sig == syscall.SIGUSR2 {
+			<-time.After(DefaultLongPress + 100*time.Millisecond)
+		}
+		c <- Event{
+			Pressed: false,
+		}
 	}
 }
@@ -0,0 +1,174 @@
+// Package chat implements a notifier that posts about releases to chat through incoming webhooks, using the message
+// format that Slack and Mattermost have in common.
+package chat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/callebjorkell/big-switch/internal/deploy"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxPending is the number of messages that can wait to be posted before new ones are dropped.
+const maxPending = 32
+
+type message struct {
+	Text     string `json:"text"`
+	Username string `json:"username,omitempty"`
+}
+
+// WebhookNotifier posts to an incoming webhook when a release is alerted about, when it is confirmed or times out, and
+// when it succeeds or fails. Messages are posted in order in the background, so that a slow chat server does not hold
+// up the alerts.
+type WebhookNotifier struct {
+	HttpClient *http.Client
+	url        string
+	username   string
+	messages   chan message
+	done       chan struct{}
+	closer     sync.Once
+	lock       sync.Mutex
+	// alerted is the alert that has already been posted about, so that refreshes of the same alert are not posted.
+	alerted string
+}
+
+// NewWebhookNotifier creates a notifier posting to the webhook at the url. The username is shown as the sender of the
+// messages if it is set and the webhook allows it to be overridden.
+func NewWebhookNotifier(url, username string) *WebhookNotifier {
+	n := &WebhookNotifier{
+		HttpClient: &http.Client{Timeout: 10 * time.Second},
+		url:        url,
+		username:   username,
+		messages:   make(chan message, maxPending),
+		done:       make(chan struct{}),
+	}
+	go n.run()
+	return n
+}
+
+// Close stops posting messages. A message that is being posted is finished, but messages that are still waiting to be
+// posted are dropped, and so are any messages made after Close.
+func (n *WebhookNotifier) Close() {
+	n.closer.Do(func() { close(n.done) })
+}
+
+func (n *WebhookNotifier) Alert(e deploy.ChangeEvent, _ int, _ time.Time) {
+	if !n.markAlerted(e, "") {
+		return
+	}
+	n.post(":rotating_light: %v%v is waiting for confirmation.", release(e), by(e.Author))
+}
+
+func (n *WebhookNotifier) Frozen(e deploy.ChangeEvent, pending int, f deploy.Freeze) {
+	if !n.markAlerted(e, f.Name) {
+		return
+	}
+	n.post(":snowflake: %v%v is waiting, but promotions are frozen by %v.", release(e), by(e.Author), f.Name)
+}
+
+func (n *WebhookNotifier) Approving(deploy.ChangeEvent, []string, int) {}
+
+func (n *WebhookNotifier) Confirmed(e deploy.ChangeEvent) {
+	n.post(":white_check_mark: %v was confirmed%v.", release(e), by(strings.Join(e.Approvers, ", ")))
+}
+
+func (n *WebhookNotifier) TimedOut(e deploy.ChangeEvent) {
+	n.post(":hourglass: %v timed out without being confirmed.", release(e))
+}
+
+func (n *WebhookNotifier) Deploying(deploy.ChangeEvent) {}
+
+func (n *WebhookNotifier) Rollback(deploy.ChangeEvent) {}
+
+func (n *WebhookNotifier) Retrying(deploy.ChangeEvent, error, int) {}
+
+func (n *WebhookNotifier) Success(e deploy.ChangeEvent) {
+	n.post(":rocket: `%v` of %v was released to %v.", e.Artifact, e.Service, e.Promotion.To)
+}
+
+func (n *WebhookNotifier) Failure(e deploy.ChangeEvent, err error) {
+	n.post(":x: `%v` of %v could not be released to %v: %v", e.Artifact, e.Service, e.Promotion.To, err)
+}
+
+func (n *WebhookNotifier) Reset() {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.alerted = ""
+}
+
+// markAlerted remembers the alert about the release, and returns false if it has already been posted about.
+func (n *WebhookNotifier) markAlerted(e deploy.ChangeEvent, freeze string) bool {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	key := fmt.Sprintf("%v/%v/%v/%v", e.Service, e.Promotion, e.Artifact, freeze)
+	if n.alerted == key {
+		return false
+	}
+	n.alerted = key
+	return true
+}
+
+func release(e deploy.ChangeEvent) string {
+	return fmt.Sprintf("Release of `%v` for %v (%v)", e.Artifact, e.Service, e.Promotion)
+}
+
+func by(name string) string {
+	if name == "" {
+		return ""
+	}
+	return " by " + name
+}
+
+func (n *WebhookNotifier) post(format string, args ...any) {
+	select {
+	case <-n.done:
+		return
+	default:
+	}
+
+	select {
+	case n.messages <- message{Text: fmt.Sprintf(format, args...), Username: n.username}:
+	default:
+		log.Warnf("Too many chat messages waiting to be posted, dropping one.")
+	}
+}
+
+func (n *WebhookNotifier) run() {
+	for {
+		select {
+		case <-n.done:
+			return
+		case m := <-n.messages:
+			if err := n.send(m); err != nil {
+				log.Warnf("Unable to post to chat webhook: %v", err)
+			}
+		}
+	}
+}
+
+func (n *WebhookNotifier) send(m message) error {
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	resp, err := n.HttpClient.Post(n.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		payload, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return fmt.Errorf("webhook responded with %v: %s", resp.Status, bytes.TrimSpace(payload))
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package chat
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/callebjorkell/big-switch/internal/deploy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newSink starts a webhook that passes the messages posted to it on the returned channel.
+func newSink(t *testing.T) (*httptest.Server, <-chan message) {
+	messages := make(chan message, 10)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		m := message{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&m))
+		messages <- m
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(s.Close)
+	return s, messages
+}
+
+func receive(t *testing.T, messages <-chan message) message {
+	select {
+	case m := <-messages:
+		return m
+	case <-time.After(time.Second):
+		require.Fail(t, "no message was posted")
+		return message{}
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	s, messages := newSink(t)
+	n := NewWebhookNotifier(s.URL, "big-switch")
+	defer n.Close()
+
+	e := deploy.ChangeEvent{Service: "some-service", Artifact: "some-artifact", Author: "Some Author", Promotion: deploy.DefaultPromotion}
+	n.Alert(e, 1, time.Now())
+	// refreshing the alert, like when the queue grows, does not post again.
+	n.Alert(e, 2, time.Now())
+	e.Approvers = []string{"switch", "alice"}
+	n.Confirmed(e)
+	n.Deploying(e)
+	n.Success(e)
+
+	m := receive(t, messages)
+	assert.Equal(t, "big-switch", m.Username)
+	assert.Equal(t, ":rotating_light: Release of `some-artifact` for some-service (dev->prod) by Some Author is waiting for confirmation.", m.Text)
+	assert.Equal(t, ":white_check_mark: Release of `some-artifact` for some-service (dev->prod) was confirmed by switch, alice.", receive(t, messages).Text)
+	assert.Equal(t, ":rocket: `some-artifact` of some-service was released to prod.", receive(t, messages).Text)
+	assert.Empty(t, messages)
+}
+
+func TestWebhookNotifier_TimeoutAndFailure(t *testing.T) {
+	s, messages := newSink(t)
+	n := NewWebhookNotifier(s.URL, "")
+	defer n.Close()
+
+	e := deploy.ChangeEvent{Service: "some-service", Artifact: "some-artifact", Promotion: deploy.DefaultPromotion}
+	n.Frozen(e, 1, deploy.Freeze{Name: "holidays"})
+	n.TimedOut(e)
+	n.Reset()
+	// a new alert about the same release is posted once the earlier one has been reset.
+	n.Alert(e, 1, time.Now())
+	n.Failure(e, errors.New("401 unauthorized"))
+
+	m := receive(t, messages)
+	assert.Empty(t, m.Username)
+	assert.Equal(t, ":snowflake: Release of `some-artifact` for some-service (dev->prod) is waiting, but promotions are frozen by holidays.", m.Text)
+	assert.Equal(t, ":hourglass: Release of `some-artifact` for some-service (dev->prod) timed out without being confirmed.", receive(t, messages).Text)
+	assert.Equal(t, ":rotating_light: Release of `some-artifact` for some-service (dev->prod) is waiting for confirmation.", receive(t, messages).Text)
+	assert.Equal(t, ":x: `some-artifact` of some-service could not be released to prod: 401 unauthorized", receive(t, messages).Text)
+}
+
+func TestWebhookNotifier_Closed(t *testing.T) {
+	s, messages := newSink(t)
+	n := NewWebhookNotifier(s.URL, "")
+	n.Close()
+	n.Close()
+
+	n.Success(deploy.ChangeEvent{Service: "some-service", Artifact: "some-artifact", Promotion: deploy.DefaultPromotion})
+	select {
+	case m := <-messages:
+		assert.Fail(t, "nothing should be posted after the notifier is closed", m.Text)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
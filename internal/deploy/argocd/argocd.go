@@ -0,0 +1,234 @@
+// Package argocd implements a deploy backend that watches and promotes Argo CD applications. Every environment of a
+// service is expected to be a separate application, like "service-dev" and "service-prod".
+package argocd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/callebjorkell/big-switch/internal/deploy"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DefaultApplication is the application name template used when nothing else has been configured.
+const DefaultApplication = "{{.Service}}-{{.Environment}}"
+
+// Mode decides how a promotion is made.
+type Mode string
+
+const (
+	// ModeRevision updates the target revision of the application, and leaves syncing to the automated sync policy.
+	ModeRevision Mode = "revision"
+	// ModeSync syncs the application to the revision, without changing the target revision of the application.
+	ModeSync Mode = "sync"
+)
+
+type Config struct {
+	// Url of the Argo CD server, like https://argocd.local.
+	Url   string
+	Token string
+	// Application is a Go template giving the name of the application of a service in an environment, using the
+	// Service, Namespace and Environment fields. Defaults to DefaultApplication.
+	Application string
+	// Mode defaults to ModeRevision.
+	Mode Mode
+}
+
+// Backend watches and promotes applications through the Argo CD API.
+type Backend struct {
+	HttpClient  *http.Client
+	url         string
+	token       string
+	application *template.Template
+	mode        Mode
+}
+
+func NewBackend(c Config) (*Backend, error) {
+	if c.Url == "" || c.Token == "" {
+		return nil, fmt.Errorf("url and token must be set")
+	}
+	if c.Application == "" {
+		c.Application = DefaultApplication
+	}
+	switch c.Mode {
+	case "":
+		c.Mode = ModeRevision
+	case ModeRevision, ModeSync:
+	default:
+		return nil, fmt.Errorf("unknown mode %q", c.Mode)
+	}
+
+	application, err := template.New("application").Option("missingkey=error").Parse(c.Application)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Backend{
+		HttpClient:  &http.Client{Timeout: 10 * time.Second},
+		url:         strings.TrimSuffix(c.Url, "/"),
+		token:       c.Token,
+		application: application,
+		mode:        c.Mode,
+	}, nil
+}
+
+// application is the part of an Argo CD application that is needed to find out what is running in it.
+type application struct {
+	Spec struct {
+		Source struct {
+			TargetRevision string `json:"targetRevision"`
+		} `json:"source"`
+	} `json:"spec"`
+	Status struct {
+		Sync struct {
+			Status   string `json:"status"`
+			Revision string `json:"revision"`
+		} `json:"sync"`
+		Health struct {
+			Status string `json:"status"`
+		} `json:"health"`
+		History []struct {
+			Revision   string    `json:"revision"`
+			DeployedAt time.Time `json:"deployedAt"`
+		} `json:"history"`
+	} `json:"status"`
+}
+
+// Ready reports whether the application is synced to its target revision, and is healthy.
+func (a application) Ready() bool {
+	return a.Status.Sync.Status == "Synced" && a.Status.Health.Status == "Healthy"
+}
+
+// Artifact is the revision that the application is synced to, with the time that it was last deployed.
+func (a application) Artifact() deploy.Artifact {
+	artifact := deploy.Artifact{Name: a.Status.Sync.Revision}
+	for _, h := range a.Status.History {
+		if h.Revision == artifact.Name && h.DeployedAt.UnixMilli() > artifact.Time {
+			artifact.Time = h.DeployedAt.UnixMilli()
+		}
+	}
+	return artifact
+}
+
+// Artifacts gives the revisions that the applications of the promotion are synced to. The times of the artifacts are
+// when they were deployed to the environment. An application is only reported when it is synced and healthy, so that a
+// release is not alerted about before it has rolled out to the source environment, and a promotion is not considered
+// rolled out while the target environment is still progressing or degraded.
+func (b *Backend) Artifacts(ctx context.Context, service, namespace string, promotion deploy.Promotion) (deploy.Artifacts, error) {
+	a := deploy.Artifacts{
+		Service:   service,
+		Promotion: promotion,
+	}
+
+	source, err := b.getApplication(ctx, service, namespace, promotion.From)
+	if err != nil {
+		return deploy.Artifacts{}, err
+	}
+	if source.Ready() {
+		a.Source = source.Artifact()
+	}
+
+	target, err := b.getApplication(ctx, service, namespace, promotion.To)
+	if err != nil {
+		return deploy.Artifacts{}, err
+	}
+	if target.Ready() {
+		a.Target = target.Artifact()
+	}
+
+	return a, nil
+}
+
+func (b *Backend) Promote(ctx context.Context, e deploy.ChangeEvent) error {
+	name, err := b.applicationName(e.Service, e.Namespace, e.Promotion.To)
+	if err != nil {
+		return err
+	}
+
+	if b.mode == ModeSync {
+		body := map[string]any{"revision": e.Artifact}
+		return b.do(ctx, http.MethodPost, "/api/v1/applications/"+url.PathEscape(name)+"/sync", body, nil)
+	}
+
+	patch, err := json.Marshal(map[string]any{
+		"spec": map[string]any{
+			"source": map[string]string{"targetRevision": e.Artifact},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	body := map[string]string{
+		"name":      name,
+		"patch":     string(patch),
+		"patchType": "merge",
+	}
+	return b.do(ctx, http.MethodPatch, "/api/v1/applications/"+url.PathEscape(name), body, nil)
+}
+
+func (b *Backend) getApplication(ctx context.Context, service, namespace, environment string) (application, error) {
+	name, err := b.applicationName(service, namespace, environment)
+	if err != nil {
+		return application{}, err
+	}
+
+	app := application{}
+	err = b.do(ctx, http.MethodGet, "/api/v1/applications/"+url.PathEscape(name), nil, &app)
+	return app, err
+}
+
+func (b *Backend) applicationName(service, namespace, environment string) (string, error) {
+	data := struct{ Service, Namespace, Environment string }{service, namespace, environment}
+	name := bytes.Buffer{}
+	if err := b.application.Execute(&name, data); err != nil {
+		return "", err
+	}
+	return name.String(), nil
+}
+
+func (b *Backend) do(ctx context.Context, method, path string, body, v any) error {
+	var reqBody io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(content)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, b.url+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+b.token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := b.HttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		// Argo CD reports errors like {"error": "...", "message": "...", "code": 5}
+		e := struct {
+			Message string `json:"message"`
+		}{}
+		_ = json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&e)
+		return &deploy.ResponseError{StatusCode: resp.StatusCode, Message: e.Message}
+	}
+
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,184 @@
+package argocd
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/callebjorkell/big-switch/internal/deploy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var applicationTemplate = `{
+  "metadata": {"name": "{{ .Name }}"},
+  "spec": {"source": {"repoURL": "https://git.local/deploy.git", "targetRevision": "{{ .Revision }}"}},
+  "status": {
+    "sync": {"status": "{{ .Sync }}", "revision": "{{ .Revision }}"},
+    "health": {"status": "{{ .Health }}"},
+    "history": [
+      {"id": 1, "revision": "old-revision", "deployedAt": "2023-01-01T10:00:00Z"},
+      {"id": 2, "revision": "{{ .Revision }}", "deployedAt": "{{ .DeployedAt }}"}
+    ]
+  }
+}`
+
+type applicationData struct {
+	Name       string
+	Revision   string
+	Sync       string
+	Health     string
+	DeployedAt string
+}
+
+// argoStub is a stand-in for the parts of the Argo CD API that the backend uses.
+type argoStub struct {
+	lock    sync.Mutex
+	apps    map[string]applicationData
+	patches map[string]string
+	syncs   map[string]string
+}
+
+func newArgoStub(t *testing.T) (*argoStub, *httptest.Server) {
+	tmpl, err := template.New("application").Parse(applicationTemplate)
+	require.NoError(t, err)
+
+	stub := &argoStub{
+		apps: map[string]applicationData{
+			"some-service-dev":  {Name: "some-service-dev", Revision: "new-revision", Sync: "Synced", Health: "Healthy", DeployedAt: "2023-01-02T10:00:00Z"},
+			"some-service-prod": {Name: "some-service-prod", Revision: "old-revision", Sync: "Synced", Health: "Healthy", DeployedAt: "2023-01-01T12:00:00Z"},
+		},
+		patches: make(map[string]string),
+		syncs:   make(map[string]string),
+	}
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		stub.lock.Lock()
+		defer stub.lock.Unlock()
+
+		if r.Header.Get("Authorization") != "Bearer argo-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid session","code":16,"message":"invalid session"}`))
+			return
+		}
+
+		name, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/v1/applications/"), "/")
+		app, ok := stub.apps[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"not found","code":5,"message":"applications.argoproj.io \"` + name + `\" not found"}`))
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && action == "":
+			tmpl.Execute(w, app)
+		case r.Method == http.MethodPatch && action == "":
+			body := struct{ Name, Patch, PatchType string }{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, name, body.Name)
+			assert.Equal(t, "merge", body.PatchType)
+			stub.patches[name] = body.Patch
+			tmpl.Execute(w, app)
+		case r.Method == http.MethodPost && action == "sync":
+			body := struct{ Revision string }{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			stub.syncs[name] = body.Revision
+			tmpl.Execute(w, app)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	t.Cleanup(s.Close)
+	return stub, s
+}
+
+func TestArtifacts(t *testing.T) {
+	_, s := newArgoStub(t)
+	b, err := NewBackend(Config{Url: s.URL, Token: "argo-token"})
+	require.NoError(t, err)
+
+	a, err := b.Artifacts(context.Background(), "some-service", "", deploy.DefaultPromotion)
+	require.NoError(t, err)
+
+	assert.Equal(t, deploy.Artifact{Name: "new-revision", Time: 1672653600000}, a.Source)
+	assert.Equal(t, deploy.Artifact{Name: "old-revision", Time: 1672574400000}, a.Target)
+	assert.True(t, a.IsTargetBehind())
+}
+
+func TestArtifacts_SourceNotReady(t *testing.T) {
+	stub, s := newArgoStub(t)
+	dev := stub.apps["some-service-dev"]
+	dev.Health = "Progressing"
+	stub.apps["some-service-dev"] = dev
+
+	b, err := NewBackend(Config{Url: s.URL, Token: "argo-token"})
+	require.NoError(t, err)
+
+	a, err := b.Artifacts(context.Background(), "some-service", "", deploy.DefaultPromotion)
+	require.NoError(t, err)
+	assert.Equal(t, deploy.Artifact{}, a.Source)
+	assert.False(t, a.IsTargetBehind())
+}
+
+func TestArtifacts_TargetDegraded(t *testing.T) {
+	stub, s := newArgoStub(t)
+	prod := stub.apps["some-service-prod"]
+	prod.Revision = "new-revision"
+	prod.Health = "Degraded"
+	stub.apps["some-service-prod"] = prod
+
+	b, err := NewBackend(Config{Url: s.URL, Token: "argo-token"})
+	require.NoError(t, err)
+
+	a, err := b.Artifacts(context.Background(), "some-service", "", deploy.DefaultPromotion)
+	require.NoError(t, err)
+	assert.Equal(t, deploy.Artifact{Name: "new-revision", Time: 1672653600000}, a.Source)
+	assert.Equal(t, deploy.Artifact{}, a.Target)
+	assert.False(t, a.IsTargetBehind())
+}
+
+func TestArtifacts_Errors(t *testing.T) {
+	_, s := newArgoStub(t)
+
+	b, err := NewBackend(Config{Url: s.URL, Token: "argo-token"})
+	require.NoError(t, err)
+	_, err = b.Artifacts(context.Background(), "other-service", "", deploy.DefaultPromotion)
+	assert.Equal(t, "404 not found", deploy.ErrorSummary(err))
+
+	b, err = NewBackend(Config{Url: s.URL, Token: "wrong-token"})
+	require.NoError(t, err)
+	_, err = b.Artifacts(context.Background(), "some-service", "", deploy.DefaultPromotion)
+	assert.Equal(t, "401 token", deploy.ErrorSummary(err))
+}
+
+func TestPromote_Revision(t *testing.T) {
+	stub, s := newArgoStub(t)
+	b, err := NewBackend(Config{Url: s.URL, Token: "argo-token"})
+	require.NoError(t, err)
+
+	err = b.Promote(context.Background(), deploy.ChangeEvent{Service: "some-service", Promotion: deploy.DefaultPromotion, Artifact: "new-revision"})
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{"spec":{"source":{"targetRevision":"new-revision"}}}`, stub.patches["some-service-prod"])
+	assert.Empty(t, stub.syncs)
+}
+
+func TestPromote_Sync(t *testing.T) {
+	stub, s := newArgoStub(t)
+	b, err := NewBackend(Config{Url: s.URL, Token: "argo-token", Application: "{{.Namespace}}-{{.Service}}-{{.Environment}}", Mode: ModeSync})
+	require.NoError(t, err)
+	stub.apps["team-some-service-prod"] = stub.apps["some-service-prod"]
+
+	err = b.Promote(context.Background(), deploy.ChangeEvent{Service: "some-service", Namespace: "team", Promotion: deploy.DefaultPromotion, Artifact: "new-revision"})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{"team-some-service-prod": "new-revision"}, stub.syncs)
+	assert.Empty(t, stub.patches)
+}
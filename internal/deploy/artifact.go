@@ -1,23 +1,46 @@
 package deploy
 
+import "fmt"
+
+const (
+	DefaultSourceEnvironment = "dev"
+	DefaultTargetEnvironment = "prod"
+)
+
+// Promotion is a single step in a promotion chain, moving an artifact from one environment to the next.
+type Promotion struct {
+	From string
+	To   string
+}
+
+// DefaultPromotion is the dev to prod promotion used when nothing else has been configured.
+var DefaultPromotion = Promotion{From: DefaultSourceEnvironment, To: DefaultTargetEnvironment}
+
+func (p Promotion) String() string {
+	return fmt.Sprintf("%s->%s", p.From, p.To)
+}
+
 type Artifacts struct {
-	Service string
-	Prod    Artifact
-	Dev     Artifact
+	Service   string
+	Promotion Promotion
+	// Source is the artifact currently running in the environment that is promoted from.
+	Source Artifact
+	// Target is the artifact currently running in the environment that is promoted to.
+	Target Artifact
 }
 
-func (a Artifacts) IsProdBehind() bool {
-	if a.Prod.Name == "" || a.Prod.Time == 0 {
-		// nothing currently deployed in prod
+func (a Artifacts) IsTargetBehind() bool {
+	if a.Target.Name == "" || a.Target.Time == 0 {
+		// nothing currently deployed in the target environment
 		return false
 	}
-	if a.Prod.Name == a.Dev.Name {
-		// same artifact in dev and prod
+	if a.Target.Name == a.Source.Name {
+		// same artifact in source and target
 		return false
 	}
 
-	if a.Prod.Time >= a.Dev.Time {
-		// prod is ahead of dev
+	if a.Target.Time >= a.Source.Time {
+		// target is ahead of source
 		return false
 	}
 
@@ -25,7 +48,7 @@ func (a Artifacts) IsProdBehind() bool {
 }
 
 func (a Artifacts) EnvironmentsMatch() bool {
-	return a.Prod.Equals(a.Dev)
+	return a.Target.Equals(a.Source)
 }
 
 type Artifact struct {
@@ -0,0 +1,150 @@
+package deploy
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditOutcome is how an alert ended.
+type AuditOutcome string
+
+const (
+	// OutcomeConfirmed is recorded when a release was confirmed and released successfully.
+	OutcomeConfirmed AuditOutcome = "confirmed"
+	// OutcomeFailed is recorded when a release was confirmed, but could not be released.
+	OutcomeFailed   AuditOutcome = "failed"
+	OutcomeSkipped  AuditOutcome = "skipped"
+	OutcomeTimedOut AuditOutcome = "timed out"
+)
+
+// AuditEntry records how an alert about a release, or a rollback, ended.
+type AuditEntry struct {
+	Time    time.Time    `json:"time"`
+	Service string       `json:"service"`
+	From    string       `json:"from"`
+	To      string       `json:"to"`
+	Outcome AuditOutcome `json:"outcome"`
+	// Rollback is set when the entry is about a rollback, in which case the source artifact is the one restored.
+	Rollback bool `json:"rollback,omitempty"`
+	// SourceArtifact is the artifact that was released, or would have been.
+	SourceArtifact string `json:"sourceArtifact"`
+	// TargetArtifact is the artifact that was running in the target environment when the release was seen.
+	TargetArtifact string    `json:"targetArtifact,omitempty"`
+	Author         string    `json:"author,omitempty"`
+	AlertedAt      time.Time `json:"alertedAt"`
+	// Source is where the action ending the alert came from, like SourceSwitch or SourceApi.
+	Source    string   `json:"source,omitempty"`
+	Approvers []string `json:"approvers,omitempty"`
+	// Response is the status reported by the backend for the release.
+	Response string `json:"response,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+func newAuditEntry(e ChangeEvent, outcome AuditOutcome, alerted time.Time, source string) AuditEntry {
+	return AuditEntry{
+		Time:           time.Now(),
+		Service:        e.Service,
+		From:           e.Promotion.From,
+		To:             e.Promotion.To,
+		Outcome:        outcome,
+		SourceArtifact: e.Artifact,
+		TargetArtifact: e.Replaced,
+		Author:         e.Author,
+		AlertedAt:      alerted,
+		Source:         source,
+		Approvers:      e.Approvers,
+	}
+}
+
+// newRollbackAuditEntry creates the entry for a rollback that restores the artifact of the change event.
+func newRollbackAuditEntry(restore ChangeEvent, outcome AuditOutcome, alerted time.Time, source string) AuditEntry {
+	entry := newAuditEntry(restore, outcome, alerted, source)
+	entry.Rollback = true
+	return entry
+}
+
+// AuditLog appends entries to a file as JSON lines. Entries are never changed or removed once written.
+type AuditLog struct {
+	lock sync.Mutex
+	file *os.File
+}
+
+// OpenAuditLog opens the audit log at the path for appending, creating the file if it does not exist.
+func OpenAuditLog(path string) (*AuditLog, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &AuditLog{file: f}, nil
+}
+
+// Record appends the entry to the log, and syncs it to disk.
+func (l *AuditLog) Record(entry AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
+
+func (l *AuditLog) Close() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.file.Close()
+}
+
+// ReadAuditLog reads every entry of the audit log at the path that the filter accepts. A nil filter accepts everything.
+func ReadAuditLog(path string, filter func(AuditEntry) bool) ([]AuditEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := make([]AuditEntry, 0)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		entry := AuditEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid entry on line %d of %v: %w", line, path, err)
+		}
+		if filter == nil || filter(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// AuditFilter selects audit entries. Empty fields match every entry.
+type AuditFilter struct {
+	Service string
+	Outcome AuditOutcome
+	// Since drops entries written before it.
+	Since time.Time
+}
+
+// Match reports whether the entry is selected by the filter.
+func (f AuditFilter) Match(entry AuditEntry) bool {
+	if f.Service != "" && entry.Service != f.Service {
+		return false
+	}
+	if f.Outcome != "" && entry.Outcome != f.Outcome {
+		return false
+	}
+	return entry.Time.After(f.Since) || entry.Time.Equal(f.Since)
+}
@@ -0,0 +1,45 @@
+package deploy
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	now := time.Now()
+
+	audit, err := OpenAuditLog(path)
+	require.NoError(t, err)
+	require.NoError(t, audit.Record(AuditEntry{Time: now.Add(-time.Hour), Service: "first-service", Outcome: OutcomeSkipped}))
+	require.NoError(t, audit.Close())
+
+	// entries are appended when the log is opened again.
+	audit, err = OpenAuditLog(path)
+	require.NoError(t, err)
+	require.NoError(t, audit.Record(AuditEntry{Time: now, Service: "second-service", Outcome: OutcomeConfirmed, Response: "ok"}))
+	require.NoError(t, audit.Record(AuditEntry{Time: now, Service: "first-service", Outcome: OutcomeFailed, Error: "boom"}))
+	require.NoError(t, audit.Close())
+
+	entries, err := ReadAuditLog(path, nil)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, "ok", entries[1].Response)
+	assert.Equal(t, "boom", entries[2].Error)
+
+	entries, err = ReadAuditLog(path, AuditFilter{Service: "first-service"}.Match)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	entries, err = ReadAuditLog(path, AuditFilter{Service: "first-service", Since: now.Add(-time.Minute)}.Match)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, OutcomeFailed, entries[0].Outcome)
+
+	entries, err = ReadAuditLog(path, AuditFilter{Outcome: OutcomeTimedOut}.Match)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
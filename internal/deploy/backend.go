@@ -0,0 +1,96 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// StatusSource reports what is currently running in the environments of a service.
+type StatusSource interface {
+	// Artifacts returns the artifacts currently running in the source and target environments of the promotion.
+	Artifacts(ctx context.Context, service, namespace string, promotion Promotion) (Artifacts, error)
+}
+
+// Backend is a release system that services can both be watched and promoted through.
+type Backend interface {
+	StatusSource
+	Deployer
+}
+
+type combined struct {
+	StatusSource
+	Deployer
+}
+
+// Combine creates a backend that watches services through the source, and promotes them with the deployer.
+func Combine(source StatusSource, deployer Deployer) Backend {
+	return combined{StatusSource: source, Deployer: deployer}
+}
+
+// Router is a Backend that passes status checks and promotions on to the backend that has been routed for the service,
+// or to the fallback backend if nothing has been routed for it.
+type Router struct {
+	lock     sync.RWMutex
+	fallback Backend
+	services map[string]Backend
+}
+
+// NewRouter creates a router with the given fallback. The fallback can be nil, in which case only routed services can
+// be watched and promoted.
+func NewRouter(fallback Backend) *Router {
+	return &Router{
+		fallback: fallback,
+		services: make(map[string]Backend),
+	}
+}
+
+// Route the service to the backend, replacing any earlier route of the service.
+func (r *Router) Route(service string, b Backend) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.services[service] = b
+}
+
+func (r *Router) Artifacts(ctx context.Context, service, namespace string, promotion Promotion) (Artifacts, error) {
+	b, err := r.backend(service)
+	if err != nil {
+		return Artifacts{}, err
+	}
+	return b.Artifacts(ctx, service, namespace, promotion)
+}
+
+func (r *Router) Promote(ctx context.Context, e ChangeEvent) error {
+	b, err := r.backend(e.Service)
+	if err != nil {
+		return err
+	}
+	return b.Promote(ctx, e)
+}
+
+// Rollback passes the rollback on to the backend of the service if it can roll back, and otherwise promotes the
+// artifact to restore.
+func (r *Router) Rollback(ctx context.Context, e ChangeEvent) error {
+	b, err := r.backend(e.Service)
+	if err != nil {
+		return err
+	}
+	if rb, ok := b.(Rollbacker); ok {
+		return rb.Rollback(ctx, e)
+	}
+	return b.Promote(ctx, e)
+}
+
+func (r *Router) backend(service string) (Backend, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if b, ok := r.services[service]; ok {
+		return b, nil
+	}
+	if r.fallback == nil {
+		return nil, fmt.Errorf("no backend configured for %v", service)
+	}
+	return r.fallback, nil
+}
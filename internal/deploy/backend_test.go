@@ -0,0 +1,37 @@
+package deploy
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	fallback := &backendMock{}
+	routed := &backendMock{}
+
+	r := NewRouter(fallback)
+	r.Route("routed-service", routed)
+
+	assert.NoError(t, r.Promote(context.Background(), ChangeEvent{Service: "routed-service", Artifact: "a"}))
+	assert.NoError(t, r.Promote(context.Background(), ChangeEvent{Service: "other-service", Artifact: "b"}))
+	assert.Equal(t, []string{"a"}, routed.promoted)
+	assert.Equal(t, []string{"b"}, fallback.promoted)
+
+	r = NewRouter(nil)
+	_, err := r.Artifacts(context.Background(), "other-service", "", DefaultPromotion)
+	assert.Error(t, err)
+}
+
+type backendMock struct {
+	promoted []string
+}
+
+func (b *backendMock) Artifacts(_ context.Context, service, _ string, promotion Promotion) (Artifacts, error) {
+	return Artifacts{Service: service, Promotion: promotion}, nil
+}
+
+func (b *backendMock) Promote(_ context.Context, e ChangeEvent) error {
+	b.promoted = append(b.promoted, e.Artifact)
+	return nil
+}
@@ -2,17 +2,38 @@ package deploy
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/callebjorkell/big-switch/internal/metrics"
+	log "github.com/sirupsen/logrus"
 	"io"
+	"math/rand"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout    = 10 * time.Second
+	defaultRetries    = 3
+	defaultBackoff    = 500 * time.Millisecond
+	defaultMaxBackoff = 10 * time.Second
 )
 
 type Client struct {
-	Token   string
-	BaseUrl *url.URL
-	Caller  string
+	Token      string
+	BaseUrl    *url.URL
+	Caller     string
+	HttpClient *http.Client
+	// Retries is the number of times that a request is retried after failing with a server or network error.
+	Retries int
+	// Backoff is the time waited before the first retry. It is doubled for every following retry, up to MaxBackoff,
+	// and jitter is added on top.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
 }
 
 func NewClient(baseUrl, token, caller string) *Client {
@@ -22,32 +43,175 @@ func NewClient(baseUrl, token, caller string) *Client {
 		Token:   token,
 		BaseUrl: u,
 		Caller:  caller,
+		HttpClient: &http.Client{
+			Timeout: defaultTimeout,
+		},
+		Retries:    defaultRetries,
+		Backoff:    defaultBackoff,
+		MaxBackoff: defaultMaxBackoff,
 	}
 }
 
+// Do sends the request to release-manager, and decodes the response into responseBody if it is not nil. Server and
+// network errors are retried with an exponential backoff, while any other error is returned right away. An unsuccessful
+// response is returned as a *ResponseError.
 func (c *Client) Do(r *http.Request, responseBody any) error {
 	r.Header.Set("Authorization", fmt.Sprintf("Bearer %v", c.Token))
 	r.Header.Set("X-Caller-Email", c.Caller)
 	r.Header.Set("Accept", "application/json")
 
-	resp, err := http.DefaultClient.Do(r)
+	backoff := c.Backoff
+	for attempt := 1; ; attempt++ {
+		err := c.do(r, responseBody)
+		if err == nil || !IsRetryable(err) || attempt > c.Retries {
+			return err
+		}
+
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		log.Debugf("Request to %v failed (%v). Retrying in %v.", r.URL.Path, err, wait)
+		if hook := retryHookFrom(r.Context()); hook != nil {
+			hook(err, attempt)
+		}
+
+		select {
+		case <-r.Context().Done():
+			return err
+		case <-time.After(wait):
+		}
+
+		backoff *= 2
+		if backoff > c.MaxBackoff {
+			backoff = c.MaxBackoff
+		}
+
+		if r.GetBody != nil {
+			body, bodyErr := r.GetBody()
+			if bodyErr != nil {
+				return bodyErr
+			}
+			r.Body = body
+		}
+	}
+}
+
+func (c *Client) do(r *http.Request, responseBody any) error {
+	resp, err := c.HttpClient.Do(r)
 	if err != nil {
+		metrics.ReleaseManagerResponses.WithLabelValues(metrics.OutcomeError).Inc()
 		return err
 	}
 	defer resp.Body.Close()
+	metrics.ReleaseManagerResponses.WithLabelValues(strconv.Itoa(resp.StatusCode)).Inc()
+
+	payload, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("received response code %v from release manager", resp.StatusCode)
+		return &ResponseError{
+			StatusCode: resp.StatusCode,
+			Message:    errorMessage(payload),
+		}
 	}
 	if responseBody == nil {
 		return nil
 	}
 
-	payload, err := io.ReadAll(resp.Body)
+	return json.Unmarshal(payload, responseBody)
+}
+
+// errorMessage picks out the message from a release-manager error response, or falls back to the start of the raw
+// payload if it is not in the expected format.
+func errorMessage(payload []byte) string {
+	errResp := struct {
+		Message string `json:"message"`
+	}{}
+	if err := json.Unmarshal(payload, &errResp); err == nil && errResp.Message != "" {
+		return errResp.Message
+	}
+
+	const maxLength = 200
+	msg := strings.TrimSpace(string(payload))
+	if len(msg) > maxLength {
+		msg = msg[:maxLength]
+	}
+	return msg
+}
+
+// Artifacts fetches the status of the service, and picks out the artifacts currently running in the source and target
+// environments of the given promotion.
+func (c *Client) Artifacts(ctx context.Context, service, namespace string, promotion Promotion) (Artifacts, error) {
+	type statusPayload struct {
+		Environments []struct {
+			Artifact
+			Environment string `json:"name"`
+		} `json:"environments"`
+	}
+
+	req, err := c.NewStatusRequest(service, namespace)
+	if err != nil {
+		return Artifacts{}, err
+	}
+
+	status := statusPayload{}
+	err = c.Do(req.WithContext(ctx), &status)
+	if err != nil {
+		return Artifacts{}, err
+	}
+
+	a := Artifacts{
+		Service:   service,
+		Promotion: promotion,
+	}
+
+	for _, env := range status.Environments {
+		switch env.Environment {
+		case promotion.From:
+			a.Source = env.Artifact
+		case promotion.To:
+			a.Target = env.Artifact
+		}
+	}
+
+	return a, nil
+}
+
+// Promote the artifact of the change event to the target environment of its promotion.
+func (c *Client) Promote(ctx context.Context, e ChangeEvent) error {
+	req, err := c.NewPromoteRequest(e.Service, e.Artifact, e.Promotion, e.Approvers...)
 	if err != nil {
 		return err
 	}
 
-	return json.Unmarshal(payload, responseBody)
+	return c.release(req.WithContext(ctx))
+}
+
+// Rollback releases the artifact of the change event to the target environment of its promotion again, rolling back
+// from the replaced artifact.
+func (c *Client) Rollback(ctx context.Context, e ChangeEvent) error {
+	req, err := c.NewRollbackRequest(e.Service, e.Artifact, e.Replaced, e.Promotion.To)
+	if err != nil {
+		return err
+	}
+
+	return c.release(req.WithContext(ctx))
+}
+
+// release sends the release request, and passes the status that release-manager responds with to the response hook of
+// the request context, if there is one.
+func (c *Client) release(r *http.Request) error {
+	resp := struct {
+		Status string `json:"status"`
+	}{}
+	if err := c.Do(r, &resp); err != nil {
+		return err
+	}
+
+	if hook := responseHookFrom(r.Context()); hook != nil {
+		hook(resp.Status)
+	}
+	return nil
 }
 
 func (c *Client) NewStatusRequest(service, namespace string) (*http.Request, error) {
@@ -62,24 +226,63 @@ func (c *Client) NewStatusRequest(service, namespace string) (*http.Request, err
 	return http.NewRequest("GET", u.String(), nil)
 }
 
-func (c *Client) NewPromoteRequest(service, artifactID string) (*http.Request, error) {
-	type ReleaseRequest struct {
-		Service        string          `json:"service"`
-		Environment    string          `json:"environment"`
-		ArtifactID     string          `json:"artifactId"`
-		CommitterName  string          `json:"committerName"`
-		CommitterEmail string          `json:"committerEmail"`
-		Intent         json.RawMessage `json:"intent"`
-	}
+type promoteIntent struct {
+	FromEnvironment string `json:"fromEnvironment"`
+}
+
+type rollbackIntent struct {
+	PreviousArtifactID string `json:"previousArtifactId"`
+}
+
+type intent struct {
+	Type     string          `json:"type"`
+	Promote  *promoteIntent  `json:"promote,omitempty"`
+	Rollback *rollbackIntent `json:"rollback,omitempty"`
+}
+
+type releaseRequest struct {
+	Service        string `json:"service"`
+	Environment    string `json:"environment"`
+	ArtifactID     string `json:"artifactId"`
+	CommitterName  string `json:"committerName"`
+	CommitterEmail string `json:"committerEmail"`
+	Intent         intent `json:"intent"`
+}
+
+// NewPromoteRequest creates a request that promotes the artifact. If approvers are given, they are recorded as the
+// committer of the release.
+func (c *Client) NewPromoteRequest(service, artifactID string, promotion Promotion, approvers ...string) (*http.Request, error) {
+	return c.newReleaseRequest(releaseRequest{
+		Service:       service,
+		Environment:   promotion.To,
+		ArtifactID:    artifactID,
+		CommitterName: strings.Join(approvers, ", "),
+		Intent: intent{
+			Type:    "Promote",
+			Promote: &promoteIntent{FromEnvironment: promotion.From},
+		},
+	})
+}
+
+// NewRollbackRequest creates a request that releases artifactID to the environment again, rolling back from the
+// previousArtifactID that is currently running there.
+func (c *Client) NewRollbackRequest(service, artifactID, previousArtifactID, environment string) (*http.Request, error) {
+	return c.newReleaseRequest(releaseRequest{
+		Service:     service,
+		Environment: environment,
+		ArtifactID:  artifactID,
+		Intent: intent{
+			Type:     "Rollback",
+			Rollback: &rollbackIntent{PreviousArtifactID: previousArtifactID},
+		},
+	})
+}
 
-	releaseReq := ReleaseRequest{
-		Service:        service,
-		Environment:    "prod",
-		ArtifactID:     artifactID,
-		CommitterName:  "Surveyor deployer",
-		CommitterEmail: c.Caller,
-		Intent:         json.RawMessage(`{"type":"Promote","promote":{"fromEnvironment":"dev"}}`),
+func (c *Client) newReleaseRequest(releaseReq releaseRequest) (*http.Request, error) {
+	if releaseReq.CommitterName == "" {
+		releaseReq.CommitterName = "Surveyor deployer"
 	}
+	releaseReq.CommitterEmail = c.Caller
 
 	body, err := json.Marshal(releaseReq)
 	if err != nil {
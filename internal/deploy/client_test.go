@@ -0,0 +1,92 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDo_RetriesServerErrors(t *testing.T) {
+	var calls int32
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		w.Write([]byte(alreadyUpToDate))
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	c := NewClient(s.URL, "asdf", "me@local.com")
+	c.Backoff = time.Millisecond
+
+	var retries []string
+	ctx := WithRetryHook(context.Background(), func(err error, attempt int) {
+		retries = append(retries, ErrorCode(err))
+	})
+
+	req, err := c.NewPromoteRequest("test-service", "some-artifact", DefaultPromotion)
+	require.NoError(t, err)
+	err = c.Do(req.WithContext(ctx), nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	assert.Equal(t, []string{"503", "503"}, retries)
+}
+
+func TestDo_GivesUpAfterRetries(t *testing.T) {
+	var calls int32
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	c := NewClient(s.URL, "asdf", "me@local.com")
+	c.Backoff = time.Millisecond
+	c.Retries = 2
+
+	req, err := c.NewStatusRequest("test-service", "")
+	require.NoError(t, err)
+	err = c.Do(req, nil)
+
+	var respErr *ResponseError
+	require.True(t, errors.As(err, &respErr))
+	assert.Equal(t, http.StatusBadGateway, respErr.StatusCode)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestDo_DoesNotRetryClientErrors(t *testing.T) {
+	var calls int32
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"status":401,"message":"please provide a valid authentication token"}`))
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	c := NewClient(s.URL, "asdf", "me@local.com")
+	c.Backoff = time.Millisecond
+
+	req, err := c.NewStatusRequest("test-service", "")
+	require.NoError(t, err)
+	err = c.Do(req, nil)
+
+	var respErr *ResponseError
+	require.True(t, errors.As(err, &respErr))
+	assert.Equal(t, http.StatusUnauthorized, respErr.StatusCode)
+	assert.Equal(t, "please provide a valid authentication token", respErr.Message)
+	assert.Equal(t, "401 token", ErrorSummary(err))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
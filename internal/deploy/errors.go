@@ -0,0 +1,131 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// ResponseError is returned when a backend responds with a status code outside of the 2xx range.
+type ResponseError struct {
+	StatusCode int
+	// Message is the error message given by the backend, or the raw response body if it could not be decoded.
+	Message string
+}
+
+func (e *ResponseError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("received response code %v", e.StatusCode)
+	}
+	return fmt.Sprintf("received response code %v: %v", e.StatusCode, e.Message)
+}
+
+// Temporary reports whether the request could succeed if it was retried.
+func (e *ResponseError) Temporary() bool {
+	return e.StatusCode >= 500
+}
+
+// IsRetryable reports whether a failed request should be retried. Server errors and errors where no response was
+// received at all are retried, while client errors and errors in handling the response are not.
+func IsRetryable(err error) bool {
+	var respErr *ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.Temporary()
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return !errors.Is(err, context.Canceled)
+	}
+	return false
+}
+
+// ErrorCode gives a very short description of what went wrong with a request to a backend. This is the status
+// code if a response was received, and otherwise a short word describing the error.
+func ErrorCode(err error) string {
+	var respErr *ResponseError
+	if errors.As(err, &respErr) {
+		return strconv.Itoa(respErr.StatusCode)
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		if urlErr.Timeout() {
+			return "timeout"
+		}
+		return "network"
+	}
+	return "error"
+}
+
+// Summarizer is implemented by errors that can describe themselves in a way that fits on a single line of the LCD.
+type Summarizer interface {
+	Summary() string
+}
+
+// ErrorSummary describes the error in a way that fits on a single line of the LCD, for example "401 token".
+func ErrorSummary(err error) string {
+	var summarizer Summarizer
+	if errors.As(err, &summarizer) {
+		return summarizer.Summary()
+	}
+	var respErr *ResponseError
+	if !errors.As(err, &respErr) {
+		return fmt.Sprintf("%v error", ErrorCode(err))
+	}
+
+	switch respErr.StatusCode {
+	case http.StatusUnauthorized:
+		return "401 token"
+	case http.StatusForbidden:
+		return "403 forbidden"
+	case http.StatusNotFound:
+		return "404 not found"
+	case http.StatusBadRequest:
+		return "400 bad request"
+	}
+	if respErr.Temporary() {
+		return fmt.Sprintf("%v server error", respErr.StatusCode)
+	}
+	return fmt.Sprintf("%v error", respErr.StatusCode)
+}
+
+// RetryHook is called before a failed request is retried. attempt is the number of the attempt that failed, starting
+// from 1.
+type RetryHook func(err error, attempt int)
+
+type retryHookKey struct{}
+
+// WithRetryHook returns a context that makes the Client call the hook whenever a request made with the context is
+// retried.
+func WithRetryHook(ctx context.Context, hook RetryHook) context.Context {
+	return context.WithValue(ctx, retryHookKey{}, hook)
+}
+
+func retryHookFrom(ctx context.Context) RetryHook {
+	hook, ok := ctx.Value(retryHookKey{}).(RetryHook)
+	if !ok {
+		return nil
+	}
+	return hook
+}
+
+// ResponseHook is called with the status that release-manager reports for a successful release request.
+type ResponseHook func(status string)
+
+type responseHookKey struct{}
+
+// WithResponseHook returns a context that makes the Client call the hook with the response to any release request made
+// with the context.
+func WithResponseHook(ctx context.Context, hook ResponseHook) context.Context {
+	return context.WithValue(ctx, responseHookKey{}, hook)
+}
+
+func responseHookFrom(ctx context.Context) ResponseHook {
+	hook, ok := ctx.Value(responseHookKey{}).(ResponseHook)
+	if !ok {
+		return nil
+	}
+	return hook
+}
@@ -0,0 +1,110 @@
+package deploy
+
+import (
+	"fmt"
+	"github.com/robfig/cron/v3"
+	"sync"
+	"time"
+)
+
+// Freeze describes why promotions are currently frozen.
+type Freeze struct {
+	// Name of the freeze window.
+	Name string
+	// AllowOverride is set if a release can still be promoted by overriding the freeze.
+	AllowOverride bool
+}
+
+// FreezeWindow is a period of time during which promotions are frozen, either recurring on a cron schedule or between
+// two fixed points in time.
+type FreezeWindow struct {
+	Freeze
+	// Services that the window applies to. The window applies to every service if it is empty.
+	Services []string
+	schedule cron.Schedule
+	duration time.Duration
+	from, to time.Time
+}
+
+// NewCronFreezeWindow creates a window that starts every time the cron spec is triggered, and lasts for the duration.
+// The spec is a standard five field cron expression, optionally prefixed with a CRON_TZ= time zone.
+func NewCronFreezeWindow(name, spec string, duration time.Duration) (FreezeWindow, error) {
+	if duration <= 0 {
+		return FreezeWindow{}, fmt.Errorf("duration of freeze window %v must be positive", name)
+	}
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return FreezeWindow{}, fmt.Errorf("invalid cron spec of freeze window %v: %w", name, err)
+	}
+	return FreezeWindow{Freeze: Freeze{Name: name}, schedule: schedule, duration: duration}, nil
+}
+
+// NewDateFreezeWindow creates a window that lasts from one point in time until another.
+func NewDateFreezeWindow(name string, from, to time.Time) (FreezeWindow, error) {
+	if !to.After(from) {
+		return FreezeWindow{}, fmt.Errorf("freeze window %v must end after it starts", name)
+	}
+	return FreezeWindow{Freeze: Freeze{Name: name}, from: from, to: to}, nil
+}
+
+// Active reports whether the window freezes promotions of the service at the given time.
+func (w FreezeWindow) Active(service string, t time.Time) bool {
+	if !w.appliesTo(service) {
+		return false
+	}
+	if w.schedule != nil {
+		// the window is active if it was started within the last duration.
+		return !w.schedule.Next(t.Add(-w.duration)).After(t)
+	}
+	return !t.Before(w.from) && t.Before(w.to)
+}
+
+func (w FreezeWindow) appliesTo(service string) bool {
+	if len(w.Services) == 0 {
+		return true
+	}
+	for _, s := range w.Services {
+		if s == service {
+			return true
+		}
+	}
+	return false
+}
+
+// FreezeCalendar holds the freeze windows that promotions are checked against.
+type FreezeCalendar struct {
+	lock    sync.RWMutex
+	windows []FreezeWindow
+}
+
+func NewFreezeCalendar(windows []FreezeWindow) *FreezeCalendar {
+	return &FreezeCalendar{windows: windows}
+}
+
+// SetWindows replaces the windows of the calendar.
+func (f *FreezeCalendar) SetWindows(windows []FreezeWindow) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.windows = windows
+}
+
+// Frozen returns the freeze that applies to the service at the given time. The boolean is false if promotions of the
+// service are not frozen. If several windows are active, a window that cannot be overridden takes precedence.
+func (f *FreezeCalendar) Frozen(service string, t time.Time) (Freeze, bool) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	var freeze Freeze
+	frozen := false
+	for _, w := range f.windows {
+		if !w.Active(service, t) {
+			continue
+		}
+		if !frozen || freeze.AllowOverride {
+			freeze = w.Freeze
+		}
+		frozen = true
+	}
+	return freeze, frozen
+}
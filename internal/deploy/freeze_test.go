@@ -0,0 +1,57 @@
+package deploy
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestFreezeWindow_Cron(t *testing.T) {
+	// friday afternoons, from 14:00 until monday morning.
+	w, err := NewCronFreezeWindow("weekend", "0 14 * * 5", 66*time.Hour)
+	require.NoError(t, err)
+
+	friday := time.Date(2023, 1, 20, 14, 0, 0, 0, time.Local)
+	assert.False(t, w.Active("some-service", friday.Add(-time.Minute)))
+	assert.True(t, w.Active("some-service", friday))
+	assert.True(t, w.Active("some-service", friday.Add(48*time.Hour)))
+	assert.False(t, w.Active("some-service", friday.Add(66*time.Hour)))
+}
+
+func TestFreezeWindow_Dates(t *testing.T) {
+	from := time.Date(2023, 12, 22, 0, 0, 0, 0, time.UTC)
+	w, err := NewDateFreezeWindow("holidays", from, from.AddDate(0, 0, 11))
+	require.NoError(t, err)
+	w.Services = []string{"some-service"}
+
+	assert.True(t, w.Active("some-service", from.Add(time.Hour)))
+	assert.False(t, w.Active("other-service", from.Add(time.Hour)))
+	assert.False(t, w.Active("some-service", from.Add(-time.Hour)))
+
+	_, err = NewDateFreezeWindow("backwards", from, from)
+	assert.Error(t, err)
+}
+
+func TestFreezeCalendar(t *testing.T) {
+	now := time.Now()
+	soft, err := NewDateFreezeWindow("soft", now.Add(-time.Hour), now.Add(time.Hour))
+	require.NoError(t, err)
+	soft.AllowOverride = true
+	incident, err := NewDateFreezeWindow("incident", now.Add(-time.Minute), now.Add(time.Minute))
+	require.NoError(t, err)
+	incident.Services = []string{"broken-service"}
+
+	calendar := NewFreezeCalendar([]FreezeWindow{soft, incident})
+
+	f, frozen := calendar.Frozen("some-service", now)
+	assert.True(t, frozen)
+	assert.Equal(t, Freeze{Name: "soft", AllowOverride: true}, f)
+
+	f, frozen = calendar.Frozen("broken-service", now)
+	assert.True(t, frozen)
+	assert.Equal(t, Freeze{Name: "incident"}, f)
+
+	_, frozen = calendar.Frozen("some-service", now.Add(2*time.Hour))
+	assert.False(t, frozen)
+}
@@ -0,0 +1,231 @@
+// Package github implements a deployer that promotes by running a GitHub Actions workflow through workflow_dispatch,
+// and waits for the run to conclude.
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/callebjorkell/big-switch/internal/deploy"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultUrl   = "https://api.github.com"
+	DefaultInput = "artifact"
+)
+
+// Client talks to the GitHub REST API.
+type Client struct {
+	HttpClient *http.Client
+	// PollInterval is how often the status of a dispatched run is checked.
+	PollInterval time.Duration
+	// Timeout is how long to wait for a dispatched run to conclude before giving up on it.
+	Timeout time.Duration
+	url     string
+	token   string
+}
+
+// NewClient creates a client for the API at the given URL, which is DefaultUrl unless GitHub Enterprise is used.
+func NewClient(url, token string) *Client {
+	return &Client{
+		HttpClient:   &http.Client{Timeout: 10 * time.Second},
+		PollInterval: 10 * time.Second,
+		Timeout:      30 * time.Minute,
+		url:          strings.TrimSuffix(url, "/"),
+		token:        token,
+	}
+}
+
+// Workflow identifies the workflow that promotes a service.
+type Workflow struct {
+	// Repo is the repository of the workflow, like "owner/repo".
+	Repo string
+	// Workflow is the file name or id of the workflow, like "deploy.yml".
+	Workflow string
+	// Ref is the branch or tag to run the workflow on.
+	Ref string
+	// Input is the name of the workflow input that is given the artifact. Defaults to DefaultInput.
+	Input string
+}
+
+// RunError is returned when a workflow run concludes with anything but success.
+type RunError struct {
+	RunID      int64
+	Conclusion string
+	Url        string
+}
+
+func (e *RunError) Error() string {
+	return fmt.Sprintf("workflow run %d concluded with %v: %v", e.RunID, e.Conclusion, e.Url)
+}
+
+func (e *RunError) Summary() string {
+	return fmt.Sprintf("run %v", e.Conclusion)
+}
+
+// Deployer promotes a service by dispatching its workflow with the artifact as input. Promote only returns once the run
+// has concluded, so that success and failure reflect the outcome of the workflow.
+type Deployer struct {
+	client   *Client
+	workflow Workflow
+}
+
+func NewDeployer(c *Client, w Workflow) *Deployer {
+	if w.Input == "" {
+		w.Input = DefaultInput
+	}
+	return &Deployer{client: c, workflow: w}
+}
+
+type workflowRun struct {
+	ID         int64     `json:"id"`
+	Status     string    `json:"status"`
+	Conclusion string    `json:"conclusion"`
+	HtmlUrl    string    `json:"html_url"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (d *Deployer) Promote(ctx context.Context, e deploy.ChangeEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, d.client.Timeout)
+	defer cancel()
+
+	dispatched, err := d.dispatch(ctx, e.Artifact)
+	if err != nil {
+		return err
+	}
+	log.Infof("Dispatched %v in %v for %s, waiting for the run to conclude.", d.workflow.Workflow, d.workflow.Repo, e.Service)
+
+	var run workflowRun
+	for {
+		var next workflowRun
+		if run.ID == 0 {
+			next, err = d.findRun(ctx, dispatched)
+		} else {
+			next, err = d.getRun(ctx, run.ID)
+		}
+		if err != nil {
+			// the workflow has been dispatched, so a failed check only fails the promotion if it will not go away.
+			if !deploy.IsRetryable(err) {
+				return err
+			}
+			log.WithError(err).Warnf("Unable to check the workflow run of %s, trying again.", e.Service)
+		} else {
+			run = next
+		}
+
+		if run.Status == "completed" {
+			if run.Conclusion != "success" {
+				return &RunError{RunID: run.ID, Conclusion: run.Conclusion, Url: run.HtmlUrl}
+			}
+			log.Infof("Workflow run %d of %s succeeded.", run.ID, e.Service)
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("gave up waiting for workflow run of %v: %w", e.Service, ctx.Err())
+		case <-time.After(d.client.PollInterval):
+		}
+	}
+}
+
+// dispatch triggers the workflow, and returns the time of the dispatch according to GitHub, so that the run can be told
+// apart from earlier runs without depending on the local clock.
+func (d *Deployer) dispatch(ctx context.Context, artifact string) (time.Time, error) {
+	body := map[string]any{
+		"ref":    d.workflow.Ref,
+		"inputs": map[string]string{d.workflow.Input: artifact},
+	}
+	resp, err := d.client.do(ctx, http.MethodPost, d.workflowPath()+"/dispatches", body, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	dispatched, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		dispatched = time.Now()
+	}
+	// the date header has a resolution of seconds, and the run could be created within the same second.
+	return dispatched.Add(-time.Second), nil
+}
+
+// findRun finds the run created by the dispatch. Until GitHub has created the run, an empty run is returned.
+func (d *Deployer) findRun(ctx context.Context, dispatched time.Time) (workflowRun, error) {
+	query := url.Values{}
+	query.Set("event", "workflow_dispatch")
+	query.Set("branch", d.workflow.Ref)
+	query.Set("created", ">="+dispatched.UTC().Format(time.RFC3339))
+
+	runs := struct {
+		WorkflowRuns []workflowRun `json:"workflow_runs"`
+	}{}
+	if _, err := d.client.do(ctx, http.MethodGet, d.workflowPath()+"/runs?"+query.Encode(), nil, &runs); err != nil {
+		return workflowRun{}, err
+	}
+
+	// runs are listed newest first, and the oldest run after the dispatch is the most likely to be ours.
+	for i := len(runs.WorkflowRuns) - 1; i >= 0; i-- {
+		if !runs.WorkflowRuns[i].CreatedAt.Before(dispatched) {
+			return runs.WorkflowRuns[i], nil
+		}
+	}
+	return workflowRun{}, nil
+}
+
+func (d *Deployer) getRun(ctx context.Context, id int64) (workflowRun, error) {
+	run := workflowRun{}
+	_, err := d.client.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%v/actions/runs/%d", d.workflow.Repo, id), nil, &run)
+	return run, err
+}
+
+func (d *Deployer) workflowPath() string {
+	return fmt.Sprintf("/repos/%v/actions/workflows/%v", d.workflow.Repo, url.PathEscape(d.workflow.Workflow))
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, v any) (*http.Response, error) {
+	var reqBody io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(content)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.url+path, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HttpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		e := struct {
+			Message string `json:"message"`
+		}{}
+		_ = json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&e)
+		return nil, &deploy.ResponseError{StatusCode: resp.StatusCode, Message: e.Message}
+	}
+
+	if v == nil {
+		return resp, nil
+	}
+	return resp, json.NewDecoder(resp.Body).Decode(v)
+}
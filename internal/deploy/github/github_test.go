@@ -0,0 +1,139 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/callebjorkell/big-switch/internal/deploy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// actionsStub is a stand-in for the workflow endpoints of the GitHub API. The dispatched run is listed from the second
+// listing onwards, and completes with the conclusion after being checked twice. The first checks of the run fail with
+// the failure status code, as long as there are failures left.
+type actionsStub struct {
+	lock       sync.Mutex
+	conclusion string
+	inputs     map[string]string
+	listings   int
+	checks     int
+	failure    int
+	failures   int
+}
+
+func newActionsStub(t *testing.T, conclusion string) (*actionsStub, *httptest.Server) {
+	stub := &actionsStub{conclusion: conclusion}
+	created := time.Now().UTC().Add(time.Second).Format(time.RFC3339)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/owner/repo/actions/workflows/deploy.yml/dispatches", func(w http.ResponseWriter, r *http.Request) {
+		stub.lock.Lock()
+		defer stub.lock.Unlock()
+
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "Bearer gh-token", r.Header.Get("Authorization"))
+		body := struct {
+			Ref    string            `json:"ref"`
+			Inputs map[string]string `json:"inputs"`
+		}{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "main", body.Ref)
+		stub.inputs = body.Inputs
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/repos/owner/repo/actions/workflows/deploy.yml/runs", func(w http.ResponseWriter, r *http.Request) {
+		stub.lock.Lock()
+		defer stub.lock.Unlock()
+
+		assert.Equal(t, "workflow_dispatch", r.URL.Query().Get("event"))
+		stub.listings++
+		if stub.listings < 2 {
+			w.Write([]byte(`{"total_count":1,"workflow_runs":[{"id":41,"status":"completed","conclusion":"success","created_at":"2023-01-01T10:00:00Z"}]}`))
+			return
+		}
+		fmt.Fprintf(w, `{"total_count":2,"workflow_runs":[{"id":42,"status":"queued","created_at":%q},{"id":41,"status":"completed","conclusion":"success","created_at":"2023-01-01T10:00:00Z"}]}`, created)
+	})
+	mux.HandleFunc("/repos/owner/repo/actions/runs/42", func(w http.ResponseWriter, r *http.Request) {
+		stub.lock.Lock()
+		defer stub.lock.Unlock()
+
+		if stub.failures > 0 {
+			stub.failures--
+			w.WriteHeader(stub.failure)
+			return
+		}
+		stub.checks++
+		if stub.checks < 2 {
+			w.Write([]byte(`{"id":42,"status":"in_progress","html_url":"https://github.local/runs/42"}`))
+			return
+		}
+		fmt.Fprintf(w, `{"id":42,"status":"completed","conclusion":%q,"html_url":"https://github.local/runs/42"}`, stub.conclusion)
+	})
+
+	s := httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return stub, s
+}
+
+func newTestDeployer(url string) *Deployer {
+	c := NewClient(url, "gh-token")
+	c.PollInterval = time.Millisecond
+	return NewDeployer(c, Workflow{Repo: "owner/repo", Workflow: "deploy.yml", Ref: "main"})
+}
+
+func TestPromote(t *testing.T) {
+	stub, s := newActionsStub(t, "success")
+
+	err := newTestDeployer(s.URL).Promote(context.Background(), deploy.ChangeEvent{Service: "some-service", Artifact: "some-artifact"})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{"artifact": "some-artifact"}, stub.inputs)
+	assert.Equal(t, 2, stub.checks)
+}
+
+func TestPromote_RunFailed(t *testing.T) {
+	_, s := newActionsStub(t, "failure")
+
+	err := newTestDeployer(s.URL).Promote(context.Background(), deploy.ChangeEvent{Service: "some-service", Artifact: "some-artifact"})
+
+	var runErr *RunError
+	require.True(t, errors.As(err, &runErr))
+	assert.Equal(t, int64(42), runErr.RunID)
+	assert.Equal(t, "run failure", deploy.ErrorSummary(err))
+}
+
+func TestPromote_CheckFailsOnce(t *testing.T) {
+	stub, s := newActionsStub(t, "success")
+	stub.failure, stub.failures = http.StatusBadGateway, 1
+
+	err := newTestDeployer(s.URL).Promote(context.Background(), deploy.ChangeEvent{Service: "some-service", Artifact: "some-artifact"})
+	require.NoError(t, err)
+	assert.Equal(t, 0, stub.failures)
+	assert.Equal(t, 2, stub.checks)
+}
+
+func TestPromote_CheckFails(t *testing.T) {
+	stub, s := newActionsStub(t, "success")
+	stub.failure, stub.failures = http.StatusNotFound, 1
+
+	err := newTestDeployer(s.URL).Promote(context.Background(), deploy.ChangeEvent{Service: "some-service", Artifact: "some-artifact"})
+	assert.Equal(t, "404 not found", deploy.ErrorSummary(err))
+	assert.Equal(t, 0, stub.checks)
+}
+
+func TestPromote_Timeout(t *testing.T) {
+	_, s := newActionsStub(t, "success")
+	d := newTestDeployer(s.URL)
+	d.client.PollInterval = time.Hour
+	d.client.Timeout = 50 * time.Millisecond
+
+	err := d.Promote(context.Background(), deploy.ChangeEvent{Service: "some-service", Artifact: "some-artifact"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
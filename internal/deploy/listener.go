@@ -0,0 +1,810 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"github.com/callebjorkell/big-switch/internal/metrics"
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+type Notifier interface {
+	// Alert about a release waiting for confirmation. pending is the total number of releases waiting, including the
+	// one being alerted about, and deadline is when the alert times out.
+	Alert(e ChangeEvent, pending int, deadline time.Time)
+	// Frozen alerts about a release that cannot be promoted because of the freeze. It replaces Alert for as long as the
+	// freeze lasts.
+	Frozen(e ChangeEvent, pending int, f Freeze)
+	// Approving is called when a release has been confirmed, but is waiting for more approvals before it is promoted.
+	Approving(e ChangeEvent, approvers []string, required int)
+	// Confirmed is called when a release has been confirmed and approved, right before it is promoted.
+	Confirmed(e ChangeEvent)
+	// TimedOut is called when the alert about a release ends without the release being confirmed and approved.
+	TimedOut(e ChangeEvent)
+	// Deploying is called when a release has been promoted, and its rollout to the target environment is tracked.
+	Deploying(e ChangeEvent)
+	// Rollback alerts about a rollback waiting for confirmation. e is the release that would be restored.
+	Rollback(e ChangeEvent)
+	// Retrying is called when an attempt to promote or roll back a release failed, and is about to be retried. attempt
+	// is the number of the attempt that failed, starting from 1.
+	Retrying(e ChangeEvent, err error, attempt int)
+	// Success is called when a release has been promoted or rolled back, and the rollout has landed if it is tracked.
+	Success(e ChangeEvent)
+	// Failure is called when a release could not be promoted or rolled back, or its rollout did not land. The error is
+	// a *ReleaseError telling which of them failed.
+	Failure(e ChangeEvent, err error)
+	Reset()
+}
+
+type Deployer interface {
+	Promote(ctx context.Context, e ChangeEvent) error
+}
+
+// Rollbacker is implemented by deployers that have a dedicated way of rolling back. The change event is the release
+// to restore, with the artifact that is rolled back from as the replaced artifact. Deployers that do not implement it
+// are rolled back by promoting the artifact to restore.
+type Rollbacker interface {
+	Rollback(ctx context.Context, e ChangeEvent) error
+}
+
+// Action is what the operator wants done with the release that is currently being alerted about.
+type Action int
+
+const (
+	// ActionConfirm promotes the release that is being alerted about.
+	ActionConfirm Action = iota
+	// ActionSkip dismisses the release that is being alerted about without promoting it.
+	ActionSkip
+	// ActionConfirmAll promotes the release that is being alerted about, as well as every release waiting in the queue.
+	ActionConfirmAll
+	// ActionOverride promotes the release that is being alerted about, even though promotions of it are frozen. It is
+	// refused if the freeze cannot be overridden.
+	ActionOverride
+	// ActionRollback asks for the last promotion to be rolled back. It is only acted on when no release is being alerted
+	// about, and the rollback still needs to be confirmed.
+	ActionRollback
+)
+
+func (a Action) String() string {
+	switch a {
+	case ActionConfirm:
+		return "confirm"
+	case ActionSkip:
+		return "skip"
+	case ActionConfirmAll:
+		return "confirm all"
+	case ActionOverride:
+		return "override"
+	case ActionRollback:
+		return "rollback"
+	}
+	return "unknown action"
+}
+
+// ChangeListener queues the releases reported by a Watcher, and alerts about them one at a time. A release is promoted
+// if it is confirmed before the alert duration runs out, and dismissed if it is skipped or the alert times out.
+type ChangeListener struct {
+	notifier       Notifier
+	promoter       Deployer
+	alertDuration  time.Duration
+	queue          *ReleaseQueue
+	state          *StateStore
+	actions        chan Action
+	lock           sync.Mutex
+	current        *Alert
+	rollout        *rolloutTracking
+	rollbacks      chan struct{}
+	rollbackWindow time.Duration
+	rollbackSource StatusSource
+	lastRelease    *rollbackCandidate
+	freezes        *FreezeCalendar
+	approvals      chan string
+	required       map[string]int
+	audit          *AuditLog
+}
+
+const (
+	// SourceSwitch is the approver recorded for confirmations received on the actions channel given to Listen.
+	SourceSwitch = "switch"
+	// SourceApi is the approver recorded for confirmations made through Act.
+	SourceApi = "api"
+)
+
+// rollbackCandidate is the rollback of the last promotion, and when it stops being possible.
+type rollbackCandidate struct {
+	restore ChangeEvent
+	until   time.Time
+}
+
+type rolloutTracking struct {
+	source   StatusSource
+	timeout  time.Duration
+	interval time.Duration
+}
+
+// ReleaseError is the error given to Notifier.Failure, wrapping the error that made the release fail.
+type ReleaseError struct {
+	Err error
+	// Rollback is set if the release was a rollback.
+	Rollback bool
+	// Rollout is set if the release was made, but its rollout to the target environment did not land.
+	Rollout bool
+}
+
+func (e *ReleaseError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ReleaseError) Unwrap() error {
+	return e.Err
+}
+
+// ErrRolloutTimeout is returned when a promoted release does not show up in the target environment in time.
+var ErrRolloutTimeout error = rolloutTimeoutError{}
+
+type rolloutTimeoutError struct{}
+
+func (rolloutTimeoutError) Error() string {
+	return "promoted artifact did not reach the target environment in time"
+}
+
+func (rolloutTimeoutError) Summary() string {
+	return "rollout timeout"
+}
+
+// Alert is a release that is currently being alerted about.
+type Alert struct {
+	ChangeEvent
+	Started  time.Time
+	Deadline time.Time
+	// Rollback is set when the alert asks for a rollback to be confirmed, in which case the change event is the release
+	// that would be restored.
+	Rollback bool
+	// Freeze is set when promotions of the release are frozen.
+	Freeze *Freeze
+}
+
+// NewChangeListener creates a listener that alerts through the notifier, and promotes confirmed releases with the
+// promoter.
+func NewChangeListener(notifier Notifier, promoter Deployer, state *StateStore, alertSeconds int) *ChangeListener {
+	alertDuration := 45 * time.Second
+	if alertSeconds > 0 {
+		alertDuration = time.Duration(alertSeconds) * time.Second
+	}
+
+	return &ChangeListener{
+		notifier:      notifier,
+		promoter:      promoter,
+		alertDuration: alertDuration,
+		queue:         NewReleaseQueue(),
+		state:         state,
+		actions:       make(chan Action),
+		rollbacks:     make(chan struct{}),
+		approvals:     make(chan string),
+		required:      make(map[string]int),
+	}
+}
+
+// EnableRolloutTracking makes the listener follow every promotion until the source reports the promoted artifact in
+// the target environment. A promotion is only considered successful once the rollout has landed, and it fails if that
+// has not happened within the timeout. The source is checked at the given interval.
+func (c *ChangeListener) EnableRolloutTracking(source StatusSource, timeout, interval time.Duration) {
+	c.rollout = &rolloutTracking{
+		source:   source,
+		timeout:  timeout,
+		interval: interval,
+	}
+}
+
+// RequireApprovals makes promotions of the service wait for the given number of approvals from different approvers. The
+// first approval is the confirmation of the alert on the switch, and the rest have to be given through Approve. A
+// confirmation made through Act does not count as an approval, since it does not tell who made it.
+func (c *ChangeListener) RequireApprovals(service string, approvals int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if approvals <= 1 {
+		delete(c.required, service)
+		return
+	}
+	c.required[service] = approvals
+}
+
+// Approve the release that is waiting for more approvals. Returns false if no release is waiting for approvals.
+func (c *ChangeListener) Approve(approver string) bool {
+	select {
+	case c.approvals <- approver:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *ChangeListener) requiredApprovals(service string) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.required[service]
+}
+
+// EnableAudit makes the listener record how every alert ends in the audit log.
+func (c *ChangeListener) EnableAudit(audit *AuditLog) {
+	c.audit = audit
+}
+
+// EnableFreezes makes the listener refuse to promote releases while the calendar has them frozen. Releases are still
+// alerted about, and can be skipped, or promoted with ActionOverride if the freeze allows it.
+func (c *ChangeListener) EnableFreezes(calendar *FreezeCalendar) {
+	c.freezes = calendar
+}
+
+// EnableRollback makes the last promotion possible to roll back for the duration of the window after it was made. The
+// artifact that a rollback restores is read from the source right before the promotion is made.
+func (c *ChangeListener) EnableRollback(source StatusSource, window time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.rollbackSource = source
+	c.rollbackWindow = window
+}
+
+// RollbackCandidate returns the release that a rollback would restore. The boolean is false if there is nothing that
+// can be rolled back.
+func (c *ChangeListener) RollbackCandidate() (ChangeEvent, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.lastRelease == nil || time.Now().After(c.lastRelease.until) {
+		return ChangeEvent{}, false
+	}
+	return c.lastRelease.restore, true
+}
+
+// RequestRollback asks for the last promotion to be rolled back, in the same way as an ActionRollback received by
+// Listen. Returns false if the listener is busy with an alert or a promotion.
+func (c *ChangeListener) RequestRollback() bool {
+	select {
+	case c.rollbacks <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Queue returns the queue of releases that are waiting for their turn to be alerted about.
+func (c *ChangeListener) Queue() *ReleaseQueue {
+	return c.queue
+}
+
+// Current returns the release that is currently being alerted about. The boolean is false if there is no ongoing alert.
+func (c *ChangeListener) Current() (Alert, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.current == nil {
+		return Alert{}, false
+	}
+	return *c.current, true
+}
+
+// Act on the release that is currently being alerted about, in the same way as an action received by Listen. Returns
+// false if there is no ongoing alert to act on.
+func (c *ChangeListener) Act(a Action) bool {
+	select {
+	case c.actions <- a:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *ChangeListener) setCurrent(a *Alert) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.current = a
+}
+
+// Listen for changes and actions until the context expires.
+func (c *ChangeListener) Listen(ctx context.Context, changes <-chan ChangeEvent, actions <-chan Action) {
+	log.Infof("%v used as alert duration", c.alertDuration)
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e, ok := <-changes:
+				if !ok {
+					return
+				}
+				c.queue.Push(e)
+				log.Infof("Queued %s for service %s (%v). %d release(s) waiting.", e.Artifact, e.Service, e.Promotion, c.queue.Len())
+			}
+		}
+	}()
+
+	for {
+		e, ok := c.queue.Pop()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return
+			case <-c.queue.Updated():
+			case a := <-actions:
+				if a == ActionRollback {
+					c.confirmRollback(ctx, actions)
+				}
+			case <-c.rollbacks:
+				c.confirmRollback(ctx, actions)
+			}
+			continue
+		}
+
+		c.alert(ctx, e, actions)
+		c.notifier.Reset()
+	}
+}
+
+func (c *ChangeListener) alert(ctx context.Context, e ChangeEvent, actions <-chan Action) {
+	log.Infof("Service %s changed (%v). Waiting for confirmation!", e.Service, e.Promotion)
+	// drain any update that happened before this alert, since the alert will show the current queue length anyway.
+	select {
+	case <-c.queue.Updated():
+	default:
+	}
+	metrics.Alerts.WithLabelValues(e.Service).Inc()
+
+	// the release is only remembered as seen once it is alerted about, so that releases that are still queued when the
+	// application restarts are alerted about after the restart.
+	c.updateState(e, func(state *ServiceState) {
+		state.LastSeen = e.Artifact
+	})
+
+	now := time.Now()
+	current := Alert{ChangeEvent: e, Started: now, Deadline: now.Add(c.alertDuration), Freeze: c.frozen(e.Service)}
+	c.setCurrent(&current)
+	defer c.setCurrent(nil)
+	c.notify(current)
+
+	timeout := time.NewTimer(c.alertDuration)
+	defer timeout.Stop()
+
+	for {
+		var a Action
+		source := SourceSwitch
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.queue.Updated():
+			// refresh the alert so that the number of waiting releases is up-to-date.
+			c.notify(current)
+			continue
+		case a = <-actions:
+		case a = <-c.actions:
+			source = SourceApi
+		case <-timeout.C:
+			log.Info("Confirmation timed out.")
+			metrics.Timeouts.WithLabelValues(e.Service).Inc()
+			c.notifier.TimedOut(e)
+			c.record(newAuditEntry(e, OutcomeTimedOut, now, ""))
+			return
+		}
+
+		if a == ActionRollback {
+			continue
+		}
+
+		// the freeze could have started or ended since the alert was raised.
+		if f := c.frozen(e.Service); !sameFreeze(f, current.Freeze) {
+			current.Freeze = f
+			c.setCurrent(&current)
+			c.notify(current)
+		}
+
+		if current.Freeze != nil && a != ActionSkip {
+			if a != ActionOverride || !current.Freeze.AllowOverride {
+				log.Infof("Refusing %v of %s, promotions are frozen by %v.", a, e.Service, current.Freeze.Name)
+				continue
+			}
+			log.Infof("Overriding freeze %v to promote %s.", current.Freeze.Name, e.Service)
+			metrics.FreezeOverrides.WithLabelValues(e.Service).Inc()
+		}
+		if a == ActionOverride {
+			a = ActionConfirm
+		}
+
+		if required := c.requiredApprovals(e.Service); required > 1 && a != ActionSkip {
+			approvers, next, ok := c.awaitApprovals(ctx, e, a, source, required, timeout.C, actions)
+			if !ok {
+				if ctx.Err() == nil {
+					c.notifier.TimedOut(e)
+					c.record(newAuditEntry(e, OutcomeTimedOut, now, source))
+				}
+				return
+			}
+			e.Approvers, a = approvers, next
+		}
+
+		c.act(ctx, e, a, now, source)
+		return
+	}
+}
+
+// awaitApprovals waits until the confirmed release has been approved by enough different approvers, the first being
+// the switch if the release was confirmed on it. Returns the approvers and the confirming action once approved, or
+// ActionSkip if the release is skipped while waiting. ok is false if the alert ends without either happening.
+func (c *ChangeListener) awaitApprovals(ctx context.Context, e ChangeEvent, a Action, source string, required int, timeout <-chan time.Time, actions <-chan Action) (approvers []string, next Action, ok bool) {
+	if source == SourceApi {
+		log.Infof("Promotion of %s confirmed through the API, waiting for %d approval(s).", e.Service, required)
+	} else {
+		approvers = []string{source}
+		log.Infof("Promotion of %s approved by %s, waiting for %d more approval(s).", e.Service, source, required-1)
+	}
+	c.notifier.Approving(e, approvers, required)
+
+	for len(approvers) < required {
+		var skip Action
+		select {
+		case <-ctx.Done():
+			return nil, a, false
+		case <-timeout:
+			log.Infof("Approval of %s timed out.", e.Service)
+			metrics.Timeouts.WithLabelValues(e.Service).Inc()
+			return nil, a, false
+		case skip = <-actions:
+		case skip = <-c.actions:
+		case approver := <-c.approvals:
+			if contains(approvers, approver) {
+				log.Infof("Ignoring second approval of %s by %s.", e.Service, approver)
+				continue
+			}
+			approvers = append(approvers, approver)
+			log.Infof("Promotion of %s approved by %s.", e.Service, approver)
+			c.notifier.Approving(e, approvers, required)
+			continue
+		}
+
+		if skip == ActionSkip {
+			return nil, ActionSkip, true
+		}
+	}
+
+	return approvers, a, true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *ChangeListener) notify(a Alert) {
+	if a.Freeze != nil {
+		c.notifier.Frozen(a.ChangeEvent, c.queue.Len()+1, *a.Freeze)
+		return
+	}
+	c.notifier.Alert(a.ChangeEvent, c.queue.Len()+1, a.Deadline)
+}
+
+// frozen returns the freeze of the service, or nil if promotions of it are not frozen.
+func (c *ChangeListener) frozen(service string) *Freeze {
+	if c.freezes == nil {
+		return nil
+	}
+	if f, ok := c.freezes.Frozen(service, time.Now()); ok {
+		return &f
+	}
+	return nil
+}
+
+func sameFreeze(a, b *Freeze) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func (c *ChangeListener) act(ctx context.Context, e ChangeEvent, a Action, alerted time.Time, source string) {
+	log.Infof("Received %v for %s of service %s.", a, e.Artifact, e.Service)
+	if a == ActionConfirm || a == ActionConfirmAll {
+		observeConfirmation(e, alerted)
+	}
+
+	switch a {
+	case ActionConfirm:
+		c.promote(ctx, e, alerted, source)
+	case ActionSkip:
+		log.Infof("Skipping %s for service %s.", e.Artifact, e.Service)
+		metrics.Skips.WithLabelValues(e.Service).Inc()
+		c.updateState(e, func(state *ServiceState) {
+			state.LastSkipped = e.Artifact
+		})
+		c.record(newAuditEntry(e, OutcomeSkipped, alerted, source))
+	case ActionConfirmAll:
+		c.promote(ctx, e, alerted, source)
+		// frozen releases and releases needing approvals are put back in the queue, to be alerted about one at a time.
+		var held []ChangeEvent
+		for next, ok := c.queue.Pop(); ok; next, ok = c.queue.Pop() {
+			if c.frozen(next.Service) != nil {
+				log.Infof("Not promoting %s, promotions are frozen.", next.Service)
+				held = append(held, next)
+				continue
+			}
+			if c.requiredApprovals(next.Service) > 1 {
+				log.Infof("Not promoting %s, it needs to be approved on its own.", next.Service)
+				held = append(held, next)
+				continue
+			}
+			// the queued release has been waiting for confirmation since it was queued, rather than since the alert.
+			observeConfirmation(next, next.Queued)
+			c.notifier.Reset()
+			c.promote(ctx, next, next.Queued, source)
+		}
+		for _, h := range held {
+			c.queue.Push(h)
+		}
+	}
+}
+
+func observeConfirmation(e ChangeEvent, alerted time.Time) {
+	metrics.Confirmations.WithLabelValues(e.Service).Inc()
+	metrics.ConfirmationDelay.WithLabelValues(e.Service).Observe(time.Since(alerted).Seconds())
+}
+
+func (c *ChangeListener) promote(ctx context.Context, e ChangeEvent, alerted time.Time, source string) {
+	log.Infof("Promoting %s for service %s to %s.", e.Artifact, e.Service, e.Promotion.To)
+	replaced, err := c.replacedArtifact(ctx, e)
+	if err != nil {
+		log.Warnf("Unable to check what is running in %s for service %s, the promotion cannot be rolled back: %v", e.Promotion.To, e.Service, err)
+	} else {
+		e.Replaced = replaced
+	}
+
+	entry := newAuditEntry(e, OutcomeConfirmed, alerted, source)
+	c.notifier.Confirmed(e)
+	if c.deploy(ctx, e, c.promoter.Promote, false, entry) {
+		if err != nil {
+			e.Replaced = ""
+		}
+		c.rememberRollback(e)
+	}
+}
+
+// replacedArtifact returns the artifact that the promotion is about to replace in the target environment. Anything could
+// have been deployed there since the release was first seen, so it is checked right before promoting. The artifact seen
+// by the watcher is returned if rollbacks are disabled.
+func (c *ChangeListener) replacedArtifact(ctx context.Context, e ChangeEvent) (string, error) {
+	c.lock.Lock()
+	source := c.rollbackSource
+	c.lock.Unlock()
+
+	if source == nil {
+		return e.Replaced, nil
+	}
+	a, err := source.Artifacts(ctx, e.Service, e.Namespace, e.Promotion)
+	if err != nil {
+		return "", err
+	}
+	return a.Target.Name, nil
+}
+
+// confirmRollback alerts about the rollback of the last promotion, and rolls back if it is confirmed before the alert
+// duration runs out.
+func (c *ChangeListener) confirmRollback(ctx context.Context, actions <-chan Action) {
+	restore, ok := c.RollbackCandidate()
+	if !ok {
+		log.Info("Nothing to roll back.")
+		return
+	}
+
+	log.Infof("Rollback of %s to %s requested for service %s. Waiting for confirmation!", restore.Replaced, restore.Artifact, restore.Service)
+	c.notifier.Rollback(restore)
+	defer c.notifier.Reset()
+
+	now := time.Now()
+	c.setCurrent(&Alert{ChangeEvent: restore, Started: now, Deadline: now.Add(c.alertDuration), Rollback: true})
+	defer c.setCurrent(nil)
+
+	timeout := time.NewTimer(c.alertDuration)
+	defer timeout.Stop()
+
+	for {
+		var a Action
+		source := SourceSwitch
+		select {
+		case <-ctx.Done():
+			return
+		case a = <-actions:
+		case a = <-c.actions:
+			source = SourceApi
+		case <-timeout.C:
+			log.Info("Rollback confirmation timed out.")
+			c.record(newRollbackAuditEntry(restore, OutcomeTimedOut, now, ""))
+			return
+		}
+
+		switch a {
+		case ActionConfirm:
+			c.rollback(ctx, restore, newRollbackAuditEntry(restore, OutcomeConfirmed, now, source))
+			return
+		case ActionSkip:
+			log.Infof("Rollback of %s cancelled.", restore.Service)
+			c.record(newRollbackAuditEntry(restore, OutcomeSkipped, now, source))
+			return
+		}
+	}
+}
+
+func (c *ChangeListener) rollback(ctx context.Context, restore ChangeEvent, entry AuditEntry) {
+	log.Infof("Rolling back service %s in %s from %s to %s.", restore.Service, restore.Promotion.To, restore.Replaced, restore.Artifact)
+	metrics.Rollbacks.WithLabelValues(restore.Service).Inc()
+
+	// the rolled back release is still newer than what is running, and should not be alerted about again.
+	c.updateState(restore, func(state *ServiceState) {
+		state.LastSkipped = restore.Replaced
+	})
+
+	release := c.promoter.Promote
+	if r, ok := c.promoter.(Rollbacker); ok {
+		release = r.Rollback
+	}
+	if c.deploy(ctx, restore, release, true, entry) {
+		c.lock.Lock()
+		c.lastRelease = nil
+		c.lock.Unlock()
+		c.updateState(restore, func(state *ServiceState) {
+			state.LastReplaced = ""
+		})
+	}
+}
+
+// deploy makes the release with the given function, and follows its rollout if rollout tracking is enabled. The
+// notifier is told about retries and the outcome, and the outcome is recorded in the audit log with the given entry.
+// Returns whether the release succeeded.
+func (c *ChangeListener) deploy(ctx context.Context, e ChangeEvent, release func(context.Context, ChangeEvent) error, rollback bool, entry AuditEntry) bool {
+	ctx = WithRetryHook(ctx, func(err error, attempt int) {
+		log.Infof("Release attempt %d of %s failed: %v", attempt, e.Service, err)
+		c.notifier.Retrying(e, err, attempt)
+	})
+	ctx = WithResponseHook(ctx, func(status string) {
+		entry.Response = status
+	})
+	defer func() {
+		entry.Time = time.Now()
+		c.record(entry)
+	}()
+
+	err := release(ctx, e)
+	if err != nil {
+		entry.Outcome, entry.Error = OutcomeFailed, err.Error()
+		log.Warn("Unable to trigger deploy: ", err)
+		metrics.PromoteFailures.WithLabelValues(e.Service).Inc()
+		c.notifier.Failure(e, &ReleaseError{Err: err, Rollback: rollback})
+		<-time.After(5 * time.Second)
+		return false
+	}
+
+	c.updateState(e, func(state *ServiceState) {
+		state.LastPromoted = e.Artifact
+	})
+
+	if c.rollout != nil {
+		c.notifier.Deploying(e)
+		if err := c.awaitRollout(ctx, e); err != nil {
+			log.Warnf("Rollout of %s for service %s did not complete: %v", e.Artifact, e.Service, err)
+			metrics.RolloutFailures.WithLabelValues(e.Service).Inc()
+			entry.Outcome, entry.Error = OutcomeFailed, err.Error()
+			c.notifier.Failure(e, &ReleaseError{Err: err, Rollback: rollback, Rollout: true})
+			<-time.After(5 * time.Second)
+			return false
+		}
+		log.Infof("Rollout of %s for service %s completed.", e.Artifact, e.Service)
+	}
+
+	c.notifier.Success(e)
+	return true
+}
+
+// rememberRollback makes the promotion possible to roll back, replacing any earlier promotion. The promotion is stored
+// in the state so that it can still be rolled back after a restart. Nothing is remembered if rollbacks are disabled, and
+// the promotion cannot be rolled back if the artifact it replaced is not known.
+func (c *ChangeListener) rememberRollback(e ChangeEvent) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.rollbackWindow <= 0 {
+		return
+	}
+	now := time.Now()
+	c.updateState(e, func(state *ServiceState) {
+		state.LastReplaced = e.Replaced
+		state.PromotedAt = now.UnixMilli()
+	})
+	if e.Replaced == "" {
+		return
+	}
+	restore := e
+	restore.Artifact, restore.Replaced = e.Replaced, e.Artifact
+	restore.Author, restore.Approvers = "", nil
+	c.lastRelease = &rollbackCandidate{restore: restore, until: now.Add(c.rollbackWindow)}
+}
+
+// ResumeRollback makes the last promotion of the service possible to roll back again after a restart, as long as it is
+// still within the rollback window and more recent than the promotion that can currently be rolled back. It has no
+// effect unless rollbacks are enabled.
+func (c *ChangeListener) ResumeRollback(service, namespace string, promotion Promotion) {
+	state := c.state.Get(service, promotion)
+	if state.LastPromoted == "" || state.LastReplaced == "" {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	until := time.UnixMilli(state.PromotedAt).Add(c.rollbackWindow)
+	if c.rollbackWindow <= 0 || time.Now().After(until) {
+		return
+	}
+	if c.lastRelease != nil && c.lastRelease.until.After(until) {
+		return
+	}
+	c.lastRelease = &rollbackCandidate{
+		restore: ChangeEvent{
+			Service:   service,
+			Namespace: namespace,
+			Promotion: promotion,
+			Artifact:  state.LastReplaced,
+			Replaced:  state.LastPromoted,
+		},
+		until: until,
+	}
+}
+
+// awaitRollout waits until the promoted artifact is running in the target environment. Errors from the status source
+// are logged and retried until the rollout times out.
+func (c *ChangeListener) awaitRollout(ctx context.Context, e ChangeEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, c.rollout.timeout)
+	defer cancel()
+
+	tick := time.NewTicker(c.rollout.interval)
+	defer tick.Stop()
+
+	for {
+		a, err := c.rollout.source.Artifacts(ctx, e.Service, e.Namespace, e.Promotion)
+		if err != nil {
+			log.Debugf("Unable to check rollout of %s: %v", e.Service, err)
+		} else if a.Target.Name == e.Artifact {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return ErrRolloutTimeout
+			}
+			return ctx.Err()
+		case <-tick.C:
+		}
+	}
+}
+
+// record appends the entry to the audit log, if auditing is enabled.
+func (c *ChangeListener) record(entry AuditEntry) {
+	if c.audit == nil {
+		return
+	}
+	if err := c.audit.Record(entry); err != nil {
+		log.Warnf("Unable to write audit entry for %s: %v", entry.Service, err)
+	}
+}
+
+func (c *ChangeListener) updateState(e ChangeEvent, update func(state *ServiceState)) {
+	if err := c.state.Update(e.Service, e.Promotion, update); err != nil {
+		log.Warnf("Unable to store state of %s: %v", e.Service, err)
+	}
+}
@@ -0,0 +1,509 @@
+package deploy
+
+import (
+	"context"
+	"github.com/callebjorkell/big-switch/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestChangeListenerNotArmed(t *testing.T) {
+	actions := make(chan Action)
+	changes := make(chan ChangeEvent)
+	notifier := NewNotifierMock()
+	promoter := NewPromoterMock(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go NewChangeListener(notifier, promoter, NewStateStore(), 45).Listen(ctx, changes, actions)
+
+	select {
+	case actions <- ActionConfirm:
+	default:
+	}
+
+	assert.True(t, promoter.NoInteraction())
+}
+
+func TestChangeListenerAlerting(t *testing.T) {
+	actions := make(chan Action)
+	changes := make(chan ChangeEvent)
+	notifier := NewNotifierMock()
+	promoter := NewPromoterMock(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go NewChangeListener(notifier, promoter, NewStateStore(), 45).Listen(ctx, changes, actions)
+
+	changes <- ChangeEvent{Service: "test-service", Artifact: "some-artifact"}
+
+	notifier.WaitForInteraction(50 * time.Millisecond)
+
+	assert.True(t, promoter.NoInteraction())
+	assert.Equal(t, "test-service", notifier.alertFor)
+}
+
+func TestChangeListenerDeploying(t *testing.T) {
+	confirmed := testutil.ToFloat64(metrics.Confirmations.WithLabelValues("test-service"))
+	actions := make(chan Action)
+	changes := make(chan ChangeEvent)
+	notifier := NewNotifierMock()
+	promoter := NewPromoterMock(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go NewChangeListener(notifier, promoter, NewStateStore(), 45).Listen(ctx, changes, actions)
+
+	changes <- ChangeEvent{Service: "test-service", Artifact: "some-artifact"}
+	notifier.WaitForInteraction(50 * time.Millisecond)
+
+	actions <- ActionConfirm
+	promoter.WaitForInteraction(80 * time.Millisecond)
+
+	assert.Equal(t, "test-service", promoter.service)
+	assert.Equal(t, "some-artifact", promoter.artifact)
+	assert.Equal(t, "test-service", notifier.alertFor)
+	assert.Equal(t, confirmed+1, testutil.ToFloat64(metrics.Confirmations.WithLabelValues("test-service")))
+}
+
+func TestChangeListenerQueueing(t *testing.T) {
+	actions := make(chan Action)
+	changes := make(chan ChangeEvent)
+	notifier := NewNotifierMock()
+	promoter := NewPromoterMock(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go NewChangeListener(notifier, promoter, NewStateStore(), 45).Listen(ctx, changes, actions)
+
+	changes <- ChangeEvent{Service: "first-service", Artifact: "first-artifact"}
+	require.True(t, notifier.WaitForInteraction(50*time.Millisecond))
+	assert.Equal(t, 1, notifier.pending)
+
+	changes <- ChangeEvent{Service: "second-service", Artifact: "second-artifact"}
+	require.True(t, notifier.WaitForInteraction(50*time.Millisecond))
+	assert.Equal(t, "first-service", notifier.alertFor)
+	assert.Equal(t, 2, notifier.pending)
+
+	actions <- ActionConfirm
+	require.True(t, promoter.WaitForInteraction(80*time.Millisecond))
+	assert.Equal(t, "first-service", promoter.service)
+
+	require.True(t, notifier.WaitForInteraction(50*time.Millisecond))
+	assert.Equal(t, "second-service", notifier.alertFor)
+	assert.Equal(t, 1, notifier.pending)
+}
+
+func TestChangeListenerSkipping(t *testing.T) {
+	actions := make(chan Action)
+	changes := make(chan ChangeEvent)
+	notifier := NewNotifierMock()
+	promoter := NewPromoterMock(nil)
+	state := NewStateStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go NewChangeListener(notifier, promoter, state, 45).Listen(ctx, changes, actions)
+
+	changes <- ChangeEvent{Service: "first-service", Artifact: "first-artifact"}
+	require.True(t, notifier.WaitForInteraction(50*time.Millisecond))
+	changes <- ChangeEvent{Service: "second-service", Artifact: "second-artifact"}
+	require.True(t, notifier.WaitForInteraction(50*time.Millisecond))
+
+	actions <- ActionSkip
+	require.True(t, notifier.WaitForInteraction(50*time.Millisecond))
+	assert.Equal(t, "second-service", notifier.alertFor)
+	assert.True(t, promoter.NoInteraction())
+	assert.Equal(t, "first-artifact", state.Get("first-service", Promotion{}).LastSkipped)
+}
+
+func TestChangeListenerConfirmAll(t *testing.T) {
+	actions := make(chan Action)
+	changes := make(chan ChangeEvent)
+	notifier := NewNotifierMock()
+	promoter := NewPromoterMock(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "audit.log")
+	audit, err := OpenAuditLog(path)
+	require.NoError(t, err)
+	defer audit.Close()
+	listener := NewChangeListener(notifier, promoter, NewStateStore(), 45)
+	listener.EnableAudit(audit)
+	go listener.Listen(ctx, changes, actions)
+
+	changes <- ChangeEvent{Service: "first-service", Artifact: "first-artifact"}
+	require.True(t, notifier.WaitForInteraction(50*time.Millisecond))
+	time.Sleep(10 * time.Millisecond)
+	changes <- ChangeEvent{Service: "second-service", Artifact: "second-artifact"}
+	require.True(t, notifier.WaitForInteraction(50*time.Millisecond))
+
+	actions <- ActionConfirmAll
+	require.True(t, promoter.WaitForInteraction(80*time.Millisecond))
+	require.True(t, promoter.WaitForInteraction(80*time.Millisecond))
+	assert.Equal(t, []string{"first-service", "second-service"}, promoter.promoted)
+
+	var entries []AuditEntry
+	require.Eventually(t, func() bool {
+		entries, err = ReadAuditLog(path, nil)
+		return err == nil && len(entries) == 2
+	}, 100*time.Millisecond, 5*time.Millisecond)
+	// the queued release is recorded as alerted about when it was queued, not when the first release was.
+	assert.True(t, entries[1].AlertedAt.After(entries[0].AlertedAt))
+}
+
+func TestChangeListenerAudit(t *testing.T) {
+	actions := make(chan Action)
+	changes := make(chan ChangeEvent)
+	notifier := NewNotifierMock()
+	promoter := NewPromoterMock(nil)
+	path := filepath.Join(t.TempDir(), "audit.log")
+	audit, err := OpenAuditLog(path)
+	require.NoError(t, err)
+	defer audit.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	listener := NewChangeListener(notifier, promoter, NewStateStore(), 45)
+	listener.EnableAudit(audit)
+	go listener.Listen(ctx, changes, actions)
+
+	changes <- ChangeEvent{Service: "first-service", Artifact: "first-artifact", Replaced: "old-artifact", Author: "someone", Promotion: DefaultPromotion}
+	require.True(t, notifier.WaitForInteraction(50*time.Millisecond))
+	changes <- ChangeEvent{Service: "second-service", Artifact: "second-artifact", Promotion: DefaultPromotion}
+	require.True(t, notifier.WaitForInteraction(50*time.Millisecond))
+
+	actions <- ActionConfirm
+	require.True(t, promoter.WaitForInteraction(50*time.Millisecond))
+	require.Equal(t, "success", notifier.WaitForOutcome(50*time.Millisecond))
+	require.True(t, notifier.WaitForInteraction(50*time.Millisecond))
+	// the listener might not be waiting for actions yet, right after the alert.
+	require.Eventually(t, func() bool { return listener.Act(ActionSkip) }, 50*time.Millisecond, time.Millisecond)
+
+	var entries []AuditEntry
+	require.Eventually(t, func() bool {
+		entries, err = ReadAuditLog(path, nil)
+		return err == nil && len(entries) == 2
+	}, 100*time.Millisecond, 5*time.Millisecond)
+
+	assert.Equal(t, "first-service", entries[0].Service)
+	assert.Equal(t, OutcomeConfirmed, entries[0].Outcome)
+	assert.Equal(t, "first-artifact", entries[0].SourceArtifact)
+	assert.Equal(t, "old-artifact", entries[0].TargetArtifact)
+	assert.Equal(t, "someone", entries[0].Author)
+	assert.Equal(t, SourceSwitch, entries[0].Source)
+	assert.False(t, entries[0].AlertedAt.IsZero())
+
+	assert.Equal(t, "second-service", entries[1].Service)
+	assert.Equal(t, OutcomeSkipped, entries[1].Outcome)
+	assert.Equal(t, SourceApi, entries[1].Source)
+}
+
+func TestChangeListenerRolloutTracking(t *testing.T) {
+	actions := make(chan Action)
+	changes := make(chan ChangeEvent)
+	notifier := NewNotifierMock()
+	promoter := NewPromoterMock(nil)
+	source := &rolloutSourceMock{landAfter: 3}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	listener := NewChangeListener(notifier, promoter, NewStateStore(), 45)
+	listener.EnableRolloutTracking(source, time.Second, time.Millisecond)
+	go listener.Listen(ctx, changes, actions)
+
+	changes <- ChangeEvent{Service: "test-service", Artifact: "some-artifact"}
+	require.True(t, notifier.WaitForInteraction(50*time.Millisecond))
+
+	actions <- ActionConfirm
+	require.True(t, promoter.WaitForInteraction(50*time.Millisecond))
+	assert.Equal(t, "deploying", notifier.WaitForOutcome(50*time.Millisecond))
+	assert.Equal(t, "success", notifier.WaitForOutcome(100*time.Millisecond))
+}
+
+func TestChangeListenerRolloutTimeout(t *testing.T) {
+	actions := make(chan Action)
+	changes := make(chan ChangeEvent)
+	notifier := NewNotifierMock()
+	promoter := NewPromoterMock(nil)
+	source := &rolloutSourceMock{landAfter: 1000}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	listener := NewChangeListener(notifier, promoter, NewStateStore(), 45)
+	listener.EnableRolloutTracking(source, 20*time.Millisecond, time.Millisecond)
+	go listener.Listen(ctx, changes, actions)
+
+	changes <- ChangeEvent{Service: "test-service", Artifact: "some-artifact"}
+	require.True(t, notifier.WaitForInteraction(50*time.Millisecond))
+
+	actions <- ActionConfirm
+	require.True(t, promoter.WaitForInteraction(50*time.Millisecond))
+	assert.Equal(t, "deploying", notifier.WaitForOutcome(50*time.Millisecond))
+	assert.Equal(t, "failure", notifier.WaitForOutcome(100*time.Millisecond))
+	var releaseErr *ReleaseError
+	require.ErrorAs(t, notifier.failure, &releaseErr)
+	assert.True(t, releaseErr.Rollout)
+	assert.False(t, releaseErr.Rollback)
+	assert.ErrorIs(t, notifier.failure, ErrRolloutTimeout)
+}
+
+func TestChangeListenerRollback(t *testing.T) {
+	actions := make(chan Action)
+	changes := make(chan ChangeEvent)
+	notifier := NewNotifierMock()
+	promoter := NewPromoterMock(nil)
+	state := NewStateStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	listener := NewChangeListener(notifier, promoter, state, 45)
+	// old-artifact has been deployed to the target environment since the release was seen by the watcher.
+	listener.EnableRollback(&targetSourceMock{target: "old-artifact"}, time.Minute)
+	go listener.Listen(ctx, changes, actions)
+
+	_, ok := listener.RollbackCandidate()
+	assert.False(t, ok)
+
+	changes <- ChangeEvent{Service: "test-service", Promotion: DefaultPromotion, Artifact: "new-artifact", Replaced: "stale-artifact"}
+	require.True(t, notifier.WaitForInteraction(50*time.Millisecond))
+	actions <- ActionConfirm
+	require.True(t, promoter.WaitForInteraction(50*time.Millisecond))
+	require.Equal(t, "success", notifier.WaitForOutcome(50*time.Millisecond))
+
+	restore, ok := listener.RollbackCandidate()
+	require.True(t, ok)
+	assert.Equal(t, "old-artifact", restore.Artifact)
+	assert.Equal(t, "new-artifact", restore.Replaced)
+
+	require.Eventually(t, listener.RequestRollback, time.Second, 5*time.Millisecond)
+	require.Equal(t, "rollback", notifier.WaitForOutcome(50*time.Millisecond))
+	current, ok := listener.Current()
+	require.True(t, ok)
+	assert.True(t, current.Rollback)
+
+	actions <- ActionConfirm
+	require.True(t, promoter.WaitForInteraction(50*time.Millisecond))
+	assert.Equal(t, "old-artifact", promoter.artifact)
+	assert.Equal(t, "success", notifier.WaitForOutcome(50*time.Millisecond))
+	assert.Equal(t, "new-artifact", state.Get("test-service", DefaultPromotion).LastSkipped)
+	assert.Empty(t, state.Get("test-service", DefaultPromotion).LastReplaced)
+
+	_, ok = listener.RollbackCandidate()
+	assert.False(t, ok)
+}
+
+func TestChangeListenerResumeRollback(t *testing.T) {
+	state := NewStateStore()
+	state.Update("test-service", DefaultPromotion, func(state *ServiceState) {
+		state.LastPromoted = "new-artifact"
+		state.LastReplaced = "old-artifact"
+		state.PromotedAt = time.Now().Add(-time.Minute).UnixMilli()
+	})
+	state.Update("stale-service", DefaultPromotion, func(state *ServiceState) {
+		state.LastPromoted = "new-artifact"
+		state.LastReplaced = "old-artifact"
+		state.PromotedAt = time.Now().Add(-time.Hour).UnixMilli()
+	})
+
+	listener := NewChangeListener(NewNotifierMock(), NewPromoterMock(nil), state, 45)
+	listener.EnableRollback(&targetSourceMock{}, 10*time.Minute)
+	listener.ResumeRollback("stale-service", "stale", DefaultPromotion)
+	_, ok := listener.RollbackCandidate()
+	assert.False(t, ok)
+
+	listener.ResumeRollback("test-service", "test", DefaultPromotion)
+	restore, ok := listener.RollbackCandidate()
+	require.True(t, ok)
+	assert.Equal(t, "test-service", restore.Service)
+	assert.Equal(t, "test", restore.Namespace)
+	assert.Equal(t, "old-artifact", restore.Artifact)
+	assert.Equal(t, "new-artifact", restore.Replaced)
+}
+
+func TestChangeListenerFrozen(t *testing.T) {
+	actions := make(chan Action)
+	changes := make(chan ChangeEvent)
+	notifier := NewNotifierMock()
+	promoter := NewPromoterMock(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	now := time.Now()
+	window, err := NewDateFreezeWindow("incident", now.Add(-time.Hour), now.Add(time.Hour))
+	require.NoError(t, err)
+	window.AllowOverride = true
+	listener := NewChangeListener(notifier, promoter, NewStateStore(), 45)
+	listener.EnableFreezes(NewFreezeCalendar([]FreezeWindow{window}))
+	go listener.Listen(ctx, changes, actions)
+
+	changes <- ChangeEvent{Service: "test-service", Artifact: "some-artifact"}
+	require.True(t, notifier.WaitForInteraction(50*time.Millisecond))
+	assert.Equal(t, "incident", notifier.frozenBy)
+
+	actions <- ActionConfirm
+	assert.False(t, promoter.WaitForInteraction(50*time.Millisecond))
+	current, ok := listener.Current()
+	require.True(t, ok)
+	require.NotNil(t, current.Freeze)
+
+	actions <- ActionOverride
+	require.True(t, promoter.WaitForInteraction(50*time.Millisecond))
+	assert.Equal(t, "some-artifact", promoter.artifact)
+}
+
+func TestChangeListenerApprovals(t *testing.T) {
+	actions := make(chan Action)
+	changes := make(chan ChangeEvent)
+	notifier := NewNotifierMock()
+	promoter := NewPromoterMock(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	listener := NewChangeListener(notifier, promoter, NewStateStore(), 45)
+	listener.RequireApprovals("test-service", 2)
+	go listener.Listen(ctx, changes, actions)
+
+	assert.False(t, listener.Approve("alice"))
+
+	changes <- ChangeEvent{Service: "test-service", Artifact: "some-artifact"}
+	require.True(t, notifier.WaitForInteraction(50*time.Millisecond))
+
+	actions <- ActionConfirm
+	require.Equal(t, "approving", notifier.WaitForOutcome(50*time.Millisecond))
+	assert.True(t, promoter.NoInteraction())
+
+	// the same approver cannot approve twice.
+	require.Eventually(t, func() bool { return listener.Approve(SourceSwitch) }, time.Second, 5*time.Millisecond)
+	assert.False(t, promoter.WaitForInteraction(20*time.Millisecond))
+
+	require.True(t, listener.Approve("alice"))
+	require.Equal(t, "approving", notifier.WaitForOutcome(50*time.Millisecond))
+	require.True(t, promoter.WaitForInteraction(50*time.Millisecond))
+	assert.Equal(t, "some-artifact", promoter.artifact)
+	assert.Equal(t, []string{SourceSwitch, "alice"}, promoter.approvers)
+}
+
+// rolloutSourceMock reports the artifact of the event in the target environment from the landAfter'th check.
+type rolloutSourceMock struct {
+	landAfter int
+	checks    int
+}
+
+func (s *rolloutSourceMock) Artifacts(_ context.Context, service, _ string, promotion Promotion) (Artifacts, error) {
+	s.checks++
+	a := Artifacts{Service: service, Promotion: promotion, Target: Artifact{Name: "old-artifact"}}
+	if s.checks >= s.landAfter {
+		a.Target.Name = "some-artifact"
+	}
+	return a, nil
+}
+
+// targetSourceMock reports the same artifact in the target environment for every service.
+type targetSourceMock struct {
+	target string
+}
+
+func (s *targetSourceMock) Artifacts(_ context.Context, service, _ string, promotion Promotion) (Artifacts, error) {
+	return Artifacts{Service: service, Promotion: promotion, Target: Artifact{Name: s.target}}, nil
+}
+
+type NotifierMock struct {
+	alertFor        string
+	pending         int
+	frozenBy        string
+	failure         error
+	interactionChan chan bool
+	// outcomes receives "approving", "deploying", "rollback", "success" and "failure" as they are notified.
+	outcomes chan string
+}
+
+func NewNotifierMock() *NotifierMock {
+	return &NotifierMock{
+		interactionChan: make(chan bool),
+		outcomes:        make(chan string, 10),
+	}
+}
+
+func (n *NotifierMock) WaitForOutcome(timeout time.Duration) string {
+	select {
+	case o := <-n.outcomes:
+		return o
+	case <-time.After(timeout):
+		return ""
+	}
+}
+
+func (n *NotifierMock) WaitForInteraction(timeout time.Duration) bool {
+	select {
+	case <-n.interactionChan:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func (n *NotifierMock) Alert(e ChangeEvent, pending int, _ time.Time) {
+	n.alertFor = e.Service
+	n.pending = pending
+	n.interactionChan <- true
+}
+
+func (n *NotifierMock) Frozen(e ChangeEvent, pending int, f Freeze) {
+	n.alertFor = e.Service
+	n.pending = pending
+	n.frozenBy = f.Name
+	n.interactionChan <- true
+}
+
+func (n *NotifierMock) Approving(ChangeEvent, []string, int) { n.outcomes <- "approving" }
+
+func (n *NotifierMock) Confirmed(ChangeEvent)            {}
+func (n *NotifierMock) TimedOut(ChangeEvent)             {}
+func (n *NotifierMock) Deploying(ChangeEvent)            { n.outcomes <- "deploying" }
+func (n *NotifierMock) Rollback(ChangeEvent)             { n.outcomes <- "rollback" }
+func (n *NotifierMock) Retrying(ChangeEvent, error, int) {}
+func (n *NotifierMock) Success(ChangeEvent)              { n.outcomes <- "success" }
+func (n *NotifierMock) Reset()                           {}
+
+func (n *NotifierMock) Failure(_ ChangeEvent, err error) {
+	n.failure = err
+	n.outcomes <- "failure"
+}
+
+type PromoterMock struct {
+	retErr            error
+	service, artifact string
+	promoted          []string
+	approvers         []string
+	interactionChan   chan bool
+}
+
+func NewPromoterMock(returnedError error) *PromoterMock {
+	return &PromoterMock{
+		retErr:          returnedError,
+		interactionChan: make(chan bool),
+	}
+}
+
+func (p *PromoterMock) Promote(_ context.Context, e ChangeEvent) error {
+	p.service = e.Service
+	p.artifact = e.Artifact
+	p.promoted = append(p.promoted, e.Service)
+	p.approvers = e.Approvers
+
+	p.interactionChan <- true
+	return p.retErr
+}
+
+func (p *PromoterMock) NoInteraction() bool {
+	select {
+	case <-p.interactionChan:
+	default:
+	}
+
+	return p.service == "" && p.artifact == ""
+}
+
+func (p *PromoterMock) WaitForInteraction(timeout time.Duration) bool {
+	select {
+	case <-p.interactionChan:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
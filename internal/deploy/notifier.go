@@ -0,0 +1,157 @@
+package deploy
+
+import (
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultNotifyTimeout is how long a MultiNotifier waits for its notifiers before moving on.
+	DefaultNotifyTimeout = 2 * time.Second
+	// maxQueuedNotifications is the number of notifications that can wait for a notifier before new ones are dropped.
+	maxQueuedNotifications = 16
+)
+
+// MultiNotifier notifies several notifiers concurrently, so that releases can be notified about on several devices at
+// once. Every notifier is called from its own goroutine, in the order that the notifications are made, and a panic in a
+// notifier is logged instead of crashing the application. A notification waits for the notifiers for at most the
+// timeout, so a slow notifier cannot hold up the alerts. Notifications for a notifier that keeps being slow are
+// dropped once too many of them are waiting.
+type MultiNotifier struct {
+	timeout time.Duration
+	workers []*notifierWorker
+	lock    sync.RWMutex
+	closed  bool
+}
+
+type notifierWorker struct {
+	notifier Notifier
+	calls    chan func(Notifier)
+}
+
+// NewMultiNotifier creates a notifier for the notifiers, waiting for at most the timeout for every notification.
+func NewMultiNotifier(timeout time.Duration, notifiers ...Notifier) *MultiNotifier {
+	m := &MultiNotifier{timeout: timeout}
+	for _, n := range notifiers {
+		w := &notifierWorker{notifier: n, calls: make(chan func(Notifier), maxQueuedNotifications)}
+		go w.run()
+		m.workers = append(m.workers, w)
+	}
+	return m
+}
+
+// Close stops the goroutines of the notifiers, once the notifications already made have been delivered. Notifications
+// made after Close are ignored.
+func (m *MultiNotifier) Close() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.closed {
+		return
+	}
+	m.closed = true
+	for _, w := range m.workers {
+		close(w.calls)
+	}
+}
+
+func (m *MultiNotifier) Alert(e ChangeEvent, pending int, deadline time.Time) {
+	m.notify("alert", func(n Notifier) { n.Alert(e, pending, deadline) })
+}
+
+func (m *MultiNotifier) Frozen(e ChangeEvent, pending int, f Freeze) {
+	m.notify("frozen", func(n Notifier) { n.Frozen(e, pending, f) })
+}
+
+func (m *MultiNotifier) Approving(e ChangeEvent, approvers []string, required int) {
+	m.notify("approving", func(n Notifier) { n.Approving(e, approvers, required) })
+}
+
+func (m *MultiNotifier) Confirmed(e ChangeEvent) {
+	m.notify("confirmed", func(n Notifier) { n.Confirmed(e) })
+}
+
+func (m *MultiNotifier) TimedOut(e ChangeEvent) {
+	m.notify("timed out", func(n Notifier) { n.TimedOut(e) })
+}
+
+func (m *MultiNotifier) Deploying(e ChangeEvent) {
+	m.notify("deploying", func(n Notifier) { n.Deploying(e) })
+}
+
+func (m *MultiNotifier) Rollback(e ChangeEvent) {
+	m.notify("rollback", func(n Notifier) { n.Rollback(e) })
+}
+
+func (m *MultiNotifier) Retrying(e ChangeEvent, err error, attempt int) {
+	m.notify("retrying", func(n Notifier) { n.Retrying(e, err, attempt) })
+}
+
+func (m *MultiNotifier) Success(e ChangeEvent) {
+	m.notify("success", func(n Notifier) { n.Success(e) })
+}
+
+func (m *MultiNotifier) Failure(e ChangeEvent, err error) {
+	m.notify("failure", func(n Notifier) { n.Failure(e, err) })
+}
+
+func (m *MultiNotifier) Reset() {
+	m.notify("reset", func(n Notifier) { n.Reset() })
+}
+
+// notify queues the call for every notifier, and waits until they have all made it or the timeout runs out.
+func (m *MultiNotifier) notify(name string, call func(Notifier)) {
+	// the lock is only held while queueing, so that Close does not have to wait for slow notifiers.
+	m.lock.RLock()
+	if m.closed {
+		m.lock.RUnlock()
+		log.Debugf("Notifiers are closed, ignoring %v notification.", name)
+		return
+	}
+
+	wg := sync.WaitGroup{}
+	for _, w := range m.workers {
+		wg.Add(1)
+		select {
+		case w.calls <- func(n Notifier) {
+			defer wg.Done()
+			call(n)
+		}:
+		default:
+			wg.Done()
+			log.Warnf("Notifier %T is not keeping up, dropping %v notification.", w.notifier, name)
+		}
+	}
+	m.lock.RUnlock()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	timeout := time.NewTimer(m.timeout)
+	defer timeout.Stop()
+
+	select {
+	case <-done:
+	case <-timeout.C:
+		log.Warnf("Timed out waiting for notifiers to handle %v notification.", name)
+	}
+}
+
+func (w *notifierWorker) run() {
+	for call := range w.calls {
+		w.call(call)
+	}
+}
+
+func (w *notifierWorker) call(call func(Notifier)) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("Notifier %T panicked: %v", w.notifier, r)
+		}
+	}()
+	call(w.notifier)
+}
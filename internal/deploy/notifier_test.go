@@ -0,0 +1,101 @@
+package deploy
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestMultiNotifier(t *testing.T) {
+	first, second := make(chan string, 10), make(chan string, 10)
+	m := NewMultiNotifier(time.Second, recordingNotifier(first), recordingNotifier(second))
+	defer m.Close()
+
+	e := ChangeEvent{Service: "some-service"}
+	m.Alert(e, 1, time.Now())
+	m.Confirmed(e)
+	m.Failure(e, errors.New("boom"))
+	m.Reset()
+
+	expected := []string{"alert", "confirmed", "failure", "reset"}
+	for _, calls := range []chan string{first, second} {
+		require.Len(t, calls, len(expected))
+		for _, name := range expected {
+			assert.Equal(t, name, <-calls)
+		}
+	}
+}
+
+func TestMultiNotifier_Isolation(t *testing.T) {
+	calls := make(chan string, 10)
+	release := make(chan struct{})
+	defer close(release)
+
+	blocking := funcNotifier(func(string) { <-release })
+	panicking := funcNotifier(func(name string) { panic(name) })
+	m := NewMultiNotifier(20*time.Millisecond, blocking, panicking, recordingNotifier(calls))
+	defer m.Close()
+
+	start := time.Now()
+	m.Alert(ChangeEvent{}, 1, time.Now())
+	m.Success(ChangeEvent{})
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+
+	// the panics of the second notifier are recovered, and the third notifier is still notified of everything.
+	assert.Equal(t, "alert", <-calls)
+	assert.Equal(t, "success", <-calls)
+}
+
+func TestMultiNotifier_Dropping(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	m := NewMultiNotifier(time.Millisecond, funcNotifier(func(string) { <-release }))
+	defer m.Close()
+
+	// the notifications for a notifier that is stuck are dropped once its queue is full, instead of blocking.
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 2*maxQueuedNotifications; i++ {
+			m.Reset()
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		require.Fail(t, "notifications blocked on a stuck notifier")
+	}
+}
+
+func TestMultiNotifier_Closed(t *testing.T) {
+	calls := make(chan string, 10)
+	m := NewMultiNotifier(time.Second, recordingNotifier(calls))
+	m.Close()
+	m.Close()
+
+	m.Reset()
+	assert.Empty(t, calls)
+}
+
+func recordingNotifier(calls chan<- string) Notifier {
+	return funcNotifier(func(name string) { calls <- name })
+}
+
+// funcNotifier calls the function with the name of every notification it gets.
+type funcNotifier func(name string)
+
+func (f funcNotifier) Alert(ChangeEvent, int, time.Time)    { f("alert") }
+func (f funcNotifier) Frozen(ChangeEvent, int, Freeze)      { f("frozen") }
+func (f funcNotifier) Approving(ChangeEvent, []string, int) { f("approving") }
+func (f funcNotifier) Confirmed(ChangeEvent)                { f("confirmed") }
+func (f funcNotifier) TimedOut(ChangeEvent)                 { f("timed out") }
+func (f funcNotifier) Deploying(ChangeEvent)                { f("deploying") }
+func (f funcNotifier) Rollback(ChangeEvent)                 { f("rollback") }
+func (f funcNotifier) Retrying(ChangeEvent, error, int)     { f("retrying") }
+func (f funcNotifier) Success(ChangeEvent)                  { f("success") }
+func (f funcNotifier) Failure(ChangeEvent, error)           { f("failure") }
+func (f funcNotifier) Reset()                               { f("reset") }
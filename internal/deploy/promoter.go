@@ -1,5 +1,7 @@
 package deploy
 
+import "context"
+
 type Promoter struct {
 	client *Client
 }
@@ -10,11 +12,11 @@ func NewPromoter(c *Client) *Promoter {
 	}
 }
 
-func (p *Promoter) Promote(service, artifactID string) error {
-	req, err := p.client.NewPromoteRequest(service, artifactID)
-	if err != nil {
-		return err
-	}
+// Promote the artifact of the change event to the target environment of its promotion.
+func (p *Promoter) Promote(ctx context.Context, e ChangeEvent) error {
+	return p.client.Promote(ctx, e)
+}
 
-	return p.client.Do(req, nil)
+func (p *Promoter) Rollback(ctx context.Context, e ChangeEvent) error {
+	return p.client.Rollback(ctx, e)
 }
@@ -9,7 +9,6 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
-	"time"
 )
 
 var alreadyUpToDate = `{
@@ -30,6 +29,7 @@ func TestPromote(t *testing.T) {
 
 		assert.Equal(t, "test-service", body["service"])
 		assert.Equal(t, "some-artifact", body["artifactId"])
+		assert.Equal(t, "prod", body["environment"])
 		assert.NotNil(t, body["intent"])
 		assert.NotEmpty(t, body["committerName"])
 		assert.NotNil(t, body["committerEmail"])
@@ -43,128 +43,61 @@ func TestPromote(t *testing.T) {
 	c := NewClient(s.URL, "asdf", "me@local.com")
 	p := NewPromoter(c)
 
-	err := p.Promote("test-service", "some-artifact")
+	response := ""
+	ctx := WithResponseHook(context.Background(), func(status string) {
+		response = status
+	})
+	err := p.Promote(ctx, ChangeEvent{Service: "test-service", Artifact: "some-artifact", Promotion: DefaultPromotion})
 	assert.NoError(t, err)
+	assert.Equal(t, "Environment 'prod' is already up-to-date", response)
 }
 
-func TestChangeListenerNotArmed(t *testing.T) {
-	confirmations := make(chan bool)
-	changes := make(chan ChangeEvent)
-	notifier := NewNotifierMock()
-	promoter := NewPromoterMock(nil)
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	go ChangeListener(ctx, notifier, promoter, 45, changes, confirmations)
-
-	select {
-	case confirmations <- true:
-	default:
-	}
-
-	assert.True(t, promoter.NoInteraction())
-}
-
-func TestChangeListenerAlerting(t *testing.T) {
-	confirmations := make(chan bool)
-	changes := make(chan ChangeEvent)
-	notifier := NewNotifierMock()
-	promoter := NewPromoterMock(nil)
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	go ChangeListener(ctx, notifier, promoter, 45, changes, confirmations)
-
-	changes <- ChangeEvent{Service: "test-service", Artifact: "some-artifact"}
-
-	notifier.WaitForInteraction(50 * time.Millisecond)
-
-	assert.True(t, promoter.NoInteraction())
-	assert.Equal(t, "test-service", notifier.alertFor)
-}
-
-func TestChangeListenerDeploying(t *testing.T) {
-	confirmations := make(chan bool)
-	changes := make(chan ChangeEvent)
-	notifier := NewNotifierMock()
-	promoter := NewPromoterMock(nil)
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	go ChangeListener(ctx, notifier, promoter, 45, changes, confirmations)
-
-	changes <- ChangeEvent{Service: "test-service", Artifact: "some-artifact"}
-	notifier.WaitForInteraction(50 * time.Millisecond)
-
-	confirmations <- true
-	promoter.WaitForInteraction(80 * time.Millisecond)
-
-	assert.Equal(t, "test-service", promoter.service)
-	assert.Equal(t, "some-artifact", promoter.artifact)
-	assert.Equal(t, "test-service", notifier.alertFor)
-}
-
-type NotifierMock struct {
-	alertFor        string
-	interactionChan chan bool
-}
-
-func NewNotifierMock() *NotifierMock {
-	return &NotifierMock{
-		interactionChan: make(chan bool),
-	}
-}
+func TestPromote_Approvers(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		body := map[string]any{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "switch, alice", body["committerName"])
+		assert.Equal(t, "me@local.com", body["committerEmail"])
 
-func (n *NotifierMock) WaitForInteraction(timeout time.Duration) bool {
-	select {
-	case <-n.interactionChan:
-		return true
-	case <-time.After(timeout):
-		return false
+		w.Header().Add("Content-Type", "application/json")
+		w.Write([]byte(alreadyUpToDate))
 	}
-}
-
-func (n *NotifierMock) Alert(service, author string) {
-	n.alertFor = service
-	n.interactionChan <- true
-}
-
-func (n *NotifierMock) Success() {}
-func (n *NotifierMock) Failure() {}
-func (n *NotifierMock) Reset()   {}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
 
-type PromoterMock struct {
-	retErr            error
-	service, artifact string
-	interactionChan   chan bool
-}
+	p := NewPromoter(NewClient(s.URL, "asdf", "me@local.com"))
 
-func NewPromoterMock(returnedError error) *PromoterMock {
-	return &PromoterMock{
-		retErr:          returnedError,
-		interactionChan: make(chan bool),
-	}
+	err := p.Promote(context.Background(), ChangeEvent{Service: "test-service", Artifact: "some-artifact", Promotion: DefaultPromotion, Approvers: []string{"switch", "alice"}})
+	assert.NoError(t, err)
 }
 
-func (p *PromoterMock) Promote(service, artifact string) error {
-	p.service = service
-	p.artifact = artifact
-
-	p.interactionChan <- true
-	return p.retErr
-}
+func TestRollback(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		body := struct {
+			ArtifactID  string `json:"artifactId"`
+			Environment string `json:"environment"`
+			Intent      struct {
+				Type     string `json:"type"`
+				Rollback struct {
+					PreviousArtifactID string `json:"previousArtifactId"`
+				} `json:"rollback"`
+			} `json:"intent"`
+		}{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		assert.Equal(t, "old-artifact", body.ArtifactID)
+		assert.Equal(t, "prod", body.Environment)
+		assert.Equal(t, "Rollback", body.Intent.Type)
+		assert.Equal(t, "new-artifact", body.Intent.Rollback.PreviousArtifactID)
 
-func (p *PromoterMock) NoInteraction() bool {
-	select {
-	case <-p.interactionChan:
-	default:
+		w.Header().Add("Content-Type", "application/json")
+		w.Write([]byte(alreadyUpToDate))
 	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
 
-	return p.service == "" && p.artifact == ""
-}
+	p := NewPromoter(NewClient(s.URL, "asdf", "me@local.com"))
 
-func (p *PromoterMock) WaitForInteraction(timeout time.Duration) bool {
-	select {
-	case <-p.interactionChan:
-		return true
-	case <-time.After(timeout):
-		return false
-	}
+	err := p.Rollback(context.Background(), ChangeEvent{Service: "test-service", Artifact: "old-artifact", Replaced: "new-artifact", Promotion: DefaultPromotion})
+	assert.NoError(t, err)
 }
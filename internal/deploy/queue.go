@@ -0,0 +1,87 @@
+package deploy
+
+import (
+	"sync"
+	"time"
+)
+
+// ReleaseQueue holds the releases that are waiting for confirmation, in the order that they were noticed. There is at
+// most one pending release per service and promotion, and pushing a newer artifact for the same service and promotion
+// replaces the queued one while keeping its place in the queue.
+type ReleaseQueue struct {
+	lock    sync.Mutex
+	pending []ChangeEvent
+	updated chan struct{}
+}
+
+func NewReleaseQueue() *ReleaseQueue {
+	return &ReleaseQueue{
+		updated: make(chan struct{}, 1),
+	}
+}
+
+// Push adds the release to the back of the queue, or replaces an already queued release for the same service and
+// promotion. The release is stamped with the time it was queued, unless it has been queued before.
+func (q *ReleaseQueue) Push(e ChangeEvent) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if e.Queued.IsZero() {
+		e.Queued = time.Now()
+	}
+
+	replaced := false
+	for i, p := range q.pending {
+		if p.Service == e.Service && p.Promotion == e.Promotion {
+			q.pending[i] = e
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		q.pending = append(q.pending, e)
+	}
+
+	// non-blocking signal. A single queued signal is enough to wake up whoever is waiting for updates.
+	select {
+	case q.updated <- struct{}{}:
+	default:
+	}
+}
+
+// Pop removes and returns the release at the front of the queue. The boolean is false if the queue is empty.
+func (q *ReleaseQueue) Pop() (ChangeEvent, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if len(q.pending) == 0 {
+		return ChangeEvent{}, false
+	}
+
+	e := q.pending[0]
+	q.pending = q.pending[1:]
+	return e, true
+}
+
+// Len returns the number of releases currently waiting in the queue.
+func (q *ReleaseQueue) Len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return len(q.pending)
+}
+
+// Pending returns a copy of the releases currently waiting in the queue, front first.
+func (q *ReleaseQueue) Pending() []ChangeEvent {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	pending := make([]ChangeEvent, len(q.pending))
+	copy(pending, q.pending)
+	return pending
+}
+
+// Updated returns a channel that receives a tick when a release has been pushed to the queue.
+func (q *ReleaseQueue) Updated() <-chan struct{} {
+	return q.updated
+}
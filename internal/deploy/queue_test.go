@@ -0,0 +1,49 @@
+package deploy
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestReleaseQueue_ReplacesSameService(t *testing.T) {
+	q := NewReleaseQueue()
+	q.Push(ChangeEvent{Service: "first", Promotion: DefaultPromotion, Artifact: "first-1"})
+	q.Push(ChangeEvent{Service: "second", Promotion: DefaultPromotion, Artifact: "second-1"})
+	q.Push(ChangeEvent{Service: "first", Promotion: DefaultPromotion, Artifact: "first-2"})
+
+	assert.Equal(t, 2, q.Len())
+
+	e, ok := q.Pop()
+	assert.True(t, ok)
+	assert.Equal(t, "first-2", e.Artifact)
+
+	e, ok = q.Pop()
+	assert.True(t, ok)
+	assert.Equal(t, "second-1", e.Artifact)
+
+	_, ok = q.Pop()
+	assert.False(t, ok)
+}
+
+func TestReleaseQueue_KeepsPromotionsApart(t *testing.T) {
+	q := NewReleaseQueue()
+	q.Push(ChangeEvent{Service: "first", Promotion: Promotion{From: "dev", To: "staging"}, Artifact: "first-1"})
+	q.Push(ChangeEvent{Service: "first", Promotion: Promotion{From: "staging", To: "prod"}, Artifact: "first-0"})
+
+	pending := q.Pending()
+	assert.Len(t, pending, 2)
+	assert.Equal(t, "staging", pending[0].Promotion.To)
+	assert.Equal(t, "prod", pending[1].Promotion.To)
+}
+
+func TestReleaseQueue_StampsQueuedTime(t *testing.T) {
+	q := NewReleaseQueue()
+	q.Push(ChangeEvent{Service: "first", Promotion: DefaultPromotion, Artifact: "first-1"})
+	queued := time.Now().Add(-time.Minute)
+	q.Push(ChangeEvent{Service: "second", Promotion: DefaultPromotion, Artifact: "second-1", Queued: queued})
+
+	pending := q.Pending()
+	assert.False(t, pending[0].Queued.IsZero())
+	assert.Equal(t, queued, pending[1].Queued, "a release that is queued again keeps its queued time")
+}
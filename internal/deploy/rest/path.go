@@ -0,0 +1,69 @@
+package rest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// lookup finds the value at the path in a decoded JSON document. The path is a dot separated list of object keys and
+// array indexes, like "data.items.0.version". A key can be followed by a filter in brackets to pick the first object of
+// an array with a matching field, like "environments[name=prod].tag".
+//
+// If any part of the path does not exist, found is false. An error is only returned for malformed paths.
+func lookup(doc any, path string) (value any, found bool, err error) {
+	value = doc
+	for _, segment := range strings.Split(path, ".") {
+		key, filter, hasFilter := strings.Cut(segment, "[")
+		if hasFilter && !strings.HasSuffix(filter, "]") {
+			return nil, false, fmt.Errorf("unterminated filter in path %v", path)
+		}
+
+		if key != "" {
+			value, found = child(value, key)
+			if !found {
+				return nil, false, nil
+			}
+		}
+
+		if hasFilter {
+			field, want, ok := strings.Cut(strings.TrimSuffix(filter, "]"), "=")
+			if !ok {
+				return nil, false, fmt.Errorf("filter in path %v must be on the form [field=value]", path)
+			}
+			value, found = match(value, field, want)
+			if !found {
+				return nil, false, nil
+			}
+		}
+	}
+	return value, true, nil
+}
+
+func child(value any, key string) (any, bool) {
+	switch v := value.(type) {
+	case map[string]any:
+		c, ok := v[key]
+		return c, ok
+	case []any:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(v) {
+			return nil, false
+		}
+		return v[i], true
+	}
+	return nil, false
+}
+
+func match(value any, field, want string) (any, bool) {
+	items, ok := value.([]any)
+	if !ok {
+		return nil, false
+	}
+	for _, item := range items {
+		if c, ok := child(item, field); ok && fmt.Sprint(c) == want {
+			return item, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,277 @@
+// Package rest implements a deploy backend for release systems that expose a plain HTTP API. Where the status is read
+// from and how promotions are triggered is configured with URL templates and JSON paths, so that systems without a
+// dedicated backend can still be used.
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/callebjorkell/big-switch/internal/deploy"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Config describes how to read the status of a service and how to promote it.
+//
+// The URLs, paths and the promotion body are Go templates. Status templates are executed with the Service, Namespace
+// and Environment fields set, and the promotion templates additionally have From, To, Artifact and Approvers set. The
+// json function quotes a value as a JSON string, for use in the promotion body.
+type Config struct {
+	// Headers are added to every request, for example to authenticate.
+	Headers map[string]string
+	Status  StatusConfig
+	Promote PromoteConfig
+}
+
+type StatusConfig struct {
+	// Url is requested once per environment. If the URL does not depend on the environment, it is only requested once
+	// per status check.
+	Url string
+	// Artifact is the JSON path to the id of the artifact running in the environment.
+	Artifact string
+	// Time is the JSON path to when the artifact was built, either as a RFC3339 string or in unix milliseconds. Without
+	// it, the target environment is never considered to be behind.
+	Time string
+	// Author is the JSON path to the author of the artifact.
+	Author string
+}
+
+type PromoteConfig struct {
+	// Method defaults to POST.
+	Method string
+	Url    string
+	Body   string
+}
+
+type templateData struct {
+	Service     string
+	Namespace   string
+	Environment string
+	From        string
+	To          string
+	Artifact    string
+	// Approvers is a comma separated list of the approvers of the promotion, if it needed more than one approval.
+	Approvers string
+}
+
+// Backend reads status and triggers promotions through a configured HTTP API.
+type Backend struct {
+	HttpClient *http.Client
+	headers    map[string]string
+	status     struct{ url, artifact, time, author *template.Template }
+	promote    struct {
+		method    string
+		url, body *template.Template
+	}
+}
+
+// NewBackend parses the templates of the config, and returns a backend using them.
+func NewBackend(c Config) (*Backend, error) {
+	b := &Backend{
+		HttpClient: &http.Client{Timeout: 10 * time.Second},
+		headers:    c.Headers,
+	}
+
+	if c.Status.Url == "" || c.Status.Artifact == "" {
+		return nil, fmt.Errorf("status url and artifact path must be set")
+	}
+	if c.Promote.Url == "" {
+		return nil, fmt.Errorf("promote url must be set")
+	}
+
+	var err error
+	parse := func(name, text string) *template.Template {
+		if err != nil || text == "" {
+			return nil
+		}
+		var t *template.Template
+		t, err = template.New(name).Option("missingkey=error").Funcs(funcs).Parse(text)
+		return t
+	}
+	b.status.url = parse("status url", c.Status.Url)
+	b.status.artifact = parse("artifact path", c.Status.Artifact)
+	b.status.time = parse("time path", c.Status.Time)
+	b.status.author = parse("author path", c.Status.Author)
+	b.promote.url = parse("promote url", c.Promote.Url)
+	b.promote.body = parse("promote body", c.Promote.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	b.promote.method = strings.ToUpper(c.Promote.Method)
+	if b.promote.method == "" {
+		b.promote.method = http.MethodPost
+	}
+
+	return b, nil
+}
+
+func (b *Backend) Artifacts(ctx context.Context, service, namespace string, promotion deploy.Promotion) (deploy.Artifacts, error) {
+	a := deploy.Artifacts{
+		Service:   service,
+		Promotion: promotion,
+	}
+
+	// responses are kept by URL, so that an API giving the status of all environments at once is only called once.
+	responses := make(map[string]any)
+	var err error
+	a.Source, err = b.artifact(ctx, responses, templateData{Service: service, Namespace: namespace, Environment: promotion.From})
+	if err != nil {
+		return deploy.Artifacts{}, err
+	}
+	a.Target, err = b.artifact(ctx, responses, templateData{Service: service, Namespace: namespace, Environment: promotion.To})
+	if err != nil {
+		return deploy.Artifacts{}, err
+	}
+
+	return a, nil
+}
+
+func (b *Backend) artifact(ctx context.Context, responses map[string]any, data templateData) (deploy.Artifact, error) {
+	url, err := execute(b.status.url, data)
+	if err != nil {
+		return deploy.Artifact{}, err
+	}
+
+	doc, ok := responses[url]
+	if !ok {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return deploy.Artifact{}, err
+		}
+		if err := b.do(req, &doc); err != nil {
+			return deploy.Artifact{}, err
+		}
+		responses[url] = doc
+	}
+
+	a := deploy.Artifact{}
+	path, err := execute(b.status.artifact, data)
+	if err != nil {
+		return deploy.Artifact{}, err
+	}
+	id, found, err := lookup(doc, path)
+	if err != nil || !found {
+		// an environment that has nothing running in it is not an error, but gives an empty artifact.
+		return a, err
+	}
+	a.Name = fmt.Sprint(id)
+
+	if b.status.author != nil {
+		path, err := execute(b.status.author, data)
+		if err != nil {
+			return deploy.Artifact{}, err
+		}
+		if author, found, _ := lookup(doc, path); found {
+			a.Author = fmt.Sprint(author)
+		}
+	}
+
+	if b.status.time != nil {
+		path, err := execute(b.status.time, data)
+		if err != nil {
+			return deploy.Artifact{}, err
+		}
+		if t, found, _ := lookup(doc, path); found {
+			a.Time, err = toMillis(t)
+			if err != nil {
+				return deploy.Artifact{}, err
+			}
+		}
+	}
+
+	return a, nil
+}
+
+func (b *Backend) Promote(ctx context.Context, e deploy.ChangeEvent) error {
+	data := templateData{
+		Service:     e.Service,
+		Namespace:   e.Namespace,
+		Environment: e.Promotion.To,
+		From:        e.Promotion.From,
+		To:          e.Promotion.To,
+		Artifact:    e.Artifact,
+		Approvers:   strings.Join(e.Approvers, ", "),
+	}
+
+	url, err := execute(b.promote.url, data)
+	if err != nil {
+		return err
+	}
+	var body io.Reader
+	if b.promote.body != nil {
+		content, err := execute(b.promote.body, data)
+		if err != nil {
+			return err
+		}
+		body = strings.NewReader(content)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, b.promote.method, url, body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return b.do(req, nil)
+}
+
+func (b *Backend) do(req *http.Request, v any) error {
+	req.Header.Set("Accept", "application/json")
+	for k, val := range b.headers {
+		req.Header.Set(k, val)
+	}
+
+	resp, err := b.HttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		content, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return &deploy.ResponseError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(content))}
+	}
+
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// funcs are available in every template.
+var funcs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+func execute(t *template.Template, data templateData) (string, error) {
+	b := bytes.Buffer{}
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func toMillis(v any) (int64, error) {
+	switch t := v.(type) {
+	case float64:
+		return int64(t), nil
+	case string:
+		parsed, err := time.Parse(time.RFC3339, t)
+		if err != nil {
+			return 0, fmt.Errorf("unable to parse deploy time: %w", err)
+		}
+		return parsed.UnixMilli(), nil
+	}
+	return 0, fmt.Errorf("unsupported deploy time %v", v)
+}
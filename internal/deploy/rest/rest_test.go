@@ -0,0 +1,172 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/callebjorkell/big-switch/internal/deploy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const statusResponse = `{
+  "environments": [
+    {"name": "dev", "build": {"version": "v2", "builtAt": "2023-01-02T10:00:00Z", "by": "Jane Doe"}},
+    {"name": "prod", "build": {"version": "v1", "builtAt": 1672567200000, "by": "John Doe"}}
+  ]
+}`
+
+func TestArtifacts(t *testing.T) {
+	var calls int
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		assert.Equal(t, "/services/some-service", r.URL.Path)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		w.Write([]byte(statusResponse))
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	b, err := NewBackend(Config{
+		Headers: map[string]string{"Authorization": "Bearer secret"},
+		Status: StatusConfig{
+			Url:      s.URL + "/services/{{.Service}}",
+			Artifact: "environments[name={{.Environment}}].build.version",
+			Time:     "environments[name={{.Environment}}].build.builtAt",
+			Author:   "environments[name={{.Environment}}].build.by",
+		},
+		Promote: PromoteConfig{Url: s.URL},
+	})
+	require.NoError(t, err)
+
+	a, err := b.Artifacts(context.Background(), "some-service", "", deploy.DefaultPromotion)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, calls, "the status should only be fetched once when the url does not depend on the environment")
+	assert.Equal(t, deploy.Artifact{Name: "v2", Time: 1672653600000, Author: "Jane Doe"}, a.Source)
+	assert.Equal(t, deploy.Artifact{Name: "v1", Time: 1672567200000, Author: "John Doe"}, a.Target)
+	assert.True(t, a.IsTargetBehind())
+}
+
+func TestArtifacts_EmptyEnvironment(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(statusResponse))
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	b, err := NewBackend(Config{
+		Status:  StatusConfig{Url: s.URL, Artifact: "environments[name={{.Environment}}].build.version"},
+		Promote: PromoteConfig{Url: s.URL},
+	})
+	require.NoError(t, err)
+
+	a, err := b.Artifacts(context.Background(), "some-service", "", deploy.Promotion{From: "dev", To: "staging"})
+	require.NoError(t, err)
+	assert.Equal(t, "v2", a.Source.Name)
+	assert.Equal(t, deploy.Artifact{}, a.Target)
+}
+
+func TestPromote(t *testing.T) {
+	var body map[string]string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "/deploy/some-service/prod", r.URL.Path)
+		content, _ := io.ReadAll(r.Body)
+		assert.NoError(t, json.Unmarshal(content, &body))
+		w.WriteHeader(http.StatusNoContent)
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	b, err := NewBackend(Config{
+		Status: StatusConfig{Url: s.URL, Artifact: "tag"},
+		Promote: PromoteConfig{
+			Method: "put",
+			Url:    s.URL + "/deploy/{{.Service}}/{{.To}}",
+			Body:   `{"artifact":"{{.Artifact}}","from":"{{.From}}"}`,
+		},
+	})
+	require.NoError(t, err)
+
+	err = b.Promote(context.Background(), deploy.ChangeEvent{
+		Service:   "some-service",
+		Promotion: deploy.DefaultPromotion,
+		Artifact:  "v2",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"artifact": "v2", "from": "dev"}, body)
+}
+
+func TestPromote_Namespace(t *testing.T) {
+	var body map[string]string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/deploy/payments/some-service", r.URL.Path)
+		content, _ := io.ReadAll(r.Body)
+		assert.NoError(t, json.Unmarshal(content, &body))
+		w.WriteHeader(http.StatusNoContent)
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	b, err := NewBackend(Config{
+		Status: StatusConfig{Url: s.URL, Artifact: "tag"},
+		Promote: PromoteConfig{
+			Url:  s.URL + "/deploy/{{.Namespace}}/{{.Service}}",
+			Body: `{"namespace":{{json .Namespace}},"artifact":{{json .Artifact}},"approvers":{{json .Approvers}}}`,
+		},
+	})
+	require.NoError(t, err)
+
+	err = b.Promote(context.Background(), deploy.ChangeEvent{
+		Service:   "some-service",
+		Namespace: "payments",
+		Promotion: deploy.DefaultPromotion,
+		Artifact:  `v2 "quoted"`,
+		Approvers: []string{"Jane Doe", "John Doe"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"namespace": "payments",
+		"artifact":  `v2 "quoted"`,
+		"approvers": "Jane Doe, John Doe",
+	}, body)
+}
+
+func TestPromote_ErrorResponse(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("not allowed"))
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	b, err := NewBackend(Config{
+		Status:  StatusConfig{Url: s.URL, Artifact: "tag"},
+		Promote: PromoteConfig{Url: s.URL},
+	})
+	require.NoError(t, err)
+
+	err = b.Promote(context.Background(), deploy.ChangeEvent{Service: "some-service", Promotion: deploy.DefaultPromotion})
+	assert.Equal(t, "403 forbidden", deploy.ErrorSummary(err))
+}
+
+func TestLookup(t *testing.T) {
+	var doc any
+	require.NoError(t, json.Unmarshal([]byte(statusResponse), &doc))
+
+	v, found, err := lookup(doc, "environments.1.build.version")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "v1", v)
+
+	_, found, err = lookup(doc, "environments[name=staging].build.version")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	_, _, err = lookup(doc, "environments[name].build")
+	assert.Error(t, err)
+}
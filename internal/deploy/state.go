@@ -0,0 +1,114 @@
+package deploy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ServiceState is what is remembered about a watched service and promotion across restarts. All artifacts are stored by
+// name, and are empty if nothing has happened yet.
+type ServiceState struct {
+	// LastSeen is the artifact that was last alerted about.
+	LastSeen    string `json:"lastSeen,omitempty"`
+	LastSkipped string `json:"lastSkipped,omitempty"`
+	// LastPromoted is the artifact that was last promoted, and LastReplaced the artifact it replaced in the target
+	// environment. LastReplaced is cleared once the promotion has been rolled back.
+	LastPromoted string `json:"lastPromoted,omitempty"`
+	LastReplaced string `json:"lastReplaced,omitempty"`
+	// PromotedAt is when LastPromoted was promoted, in unix milliseconds.
+	PromotedAt int64 `json:"promotedAt,omitempty"`
+}
+
+// StateStore keeps the ServiceState of all watched services. If the store has been opened from a file, every update is
+// written back to that file so that the state survives a restart.
+type StateStore struct {
+	path     string
+	lock     sync.Mutex
+	services map[string]ServiceState
+}
+
+// NewStateStore creates a store that is only kept in memory.
+func NewStateStore() *StateStore {
+	return &StateStore{
+		services: make(map[string]ServiceState),
+	}
+}
+
+// OpenStateStore creates a store that is backed by the given file. The file does not need to exist, and will be created
+// on the first update.
+func OpenStateStore(path string) (*StateStore, error) {
+	s := NewStateStore()
+	s.path = path
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(content, &s.services); err != nil {
+		return nil, fmt.Errorf("could not decode state file %v: %w", path, err)
+	}
+	return s, nil
+}
+
+// Get the state of the service and promotion. The zero value is returned if nothing is known about it.
+func (s *StateStore) Get(service string, promotion Promotion) ServiceState {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.services[stateKey(service, promotion)]
+}
+
+// Update the state of the service and promotion, and persist the state of all services.
+func (s *StateStore) Update(service string, promotion Promotion, update func(state *ServiceState)) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	key := stateKey(service, promotion)
+	state := s.services[key]
+	update(&state)
+	s.services[key] = state
+
+	return s.save()
+}
+
+// save writes the state to a temporary file that then replaces the state file, so that a crash halfway through the
+// write does not leave a broken state file behind.
+func (s *StateStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	content, err := json.MarshalIndent(s.services, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func stateKey(service string, promotion Promotion) string {
+	return fmt.Sprintf("%s/%v", service, promotion)
+}
@@ -0,0 +1,26 @@
+package deploy
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestStateStore_SurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	s, err := OpenStateStore(path)
+	require.NoError(t, err)
+	assert.Equal(t, ServiceState{}, s.Get("some-service", DefaultPromotion))
+
+	err = s.Update("some-service", DefaultPromotion, func(state *ServiceState) {
+		state.LastSkipped = "skipped-artifact"
+	})
+	require.NoError(t, err)
+
+	reopened, err := OpenStateStore(path)
+	require.NoError(t, err)
+	assert.Equal(t, "skipped-artifact", reopened.Get("some-service", DefaultPromotion).LastSkipped)
+	assert.Equal(t, ServiceState{}, reopened.Get("some-service", Promotion{From: "staging", To: "prod"}))
+}
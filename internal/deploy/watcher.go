@@ -2,15 +2,104 @@ package deploy
 
 import (
 	"context"
-	"github.com/callebjorkell/big-switch/internal/lcd"
+	"errors"
+	"fmt"
+	"github.com/callebjorkell/big-switch/internal/metrics"
 	log "github.com/sirupsen/logrus"
+	"sync"
 	"time"
 )
 
 type ChangeEvent struct {
-	Service  string
-	Artifact string
-	Author   string
+	Service   string
+	Namespace string
+	Promotion Promotion
+	Artifact  string
+	Author    string
+	// Replaced is the artifact that was running in the target environment when the change was seen.
+	Replaced string
+	// Approvers of the promotion, if it required more than one approval.
+	Approvers []string
+	// Queued is when the release was put in the queue of releases waiting for confirmation.
+	Queued time.Time
+}
+
+// ErrNotWatched is returned when trying to manage the watch of a service that is not being watched.
+var ErrNotWatched = errors.New("service is not watched")
+
+// WatchStatus is a snapshot of a single watch, with the artifacts that were seen the last time the service was checked.
+type WatchStatus struct {
+	Service     string
+	Namespace   string
+	Promotion   Promotion
+	Paused      bool
+	Artifacts   Artifacts
+	LastChecked time.Time
+}
+
+// Watch is a handle to a single watched service and promotion, as returned by Watcher.AddWatch.
+type Watch struct {
+	lock    sync.Mutex
+	status  WatchStatus
+	cancel  context.CancelFunc
+	done    chan struct{}
+	refresh chan struct{}
+	watcher *Watcher
+}
+
+func (w *Watch) Status() WatchStatus {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.status
+}
+
+// Pause stops checking for new releases until the watch is resumed.
+func (w *Watch) Pause() {
+	w.setPaused(true)
+}
+
+// Resume starts checking for new releases again after the watch has been paused.
+func (w *Watch) Resume() {
+	w.setPaused(false)
+}
+
+// Refresh makes the watch check for new releases right away, instead of waiting for the next polling interval.
+func (w *Watch) Refresh() {
+	// non-blocking refresh. If a refresh is already pending, the watch will check the latest status anyway.
+	select {
+	case w.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// Stop the watch and remove it from the watcher. Blocks until the watch has stopped.
+func (w *Watch) Stop() {
+	w.watcher.remove(w)
+	w.cancel()
+	<-w.done
+}
+
+func (w *Watch) isPaused() bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.status.Paused
+}
+
+func (w *Watch) setPaused(paused bool) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.status.Paused = paused
+}
+
+func (w *Watch) checked(a Artifacts) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.status.Artifacts = a
+	w.status.LastChecked = time.Now()
 }
 
 type Watcher struct {
@@ -18,179 +107,285 @@ type Watcher struct {
 	killSwitch func()
 	ctx        context.Context
 	changes    chan ChangeEvent
-	client     *Client
+	source     StatusSource
+	state      *StateStore
+	lock       sync.Mutex
+	watches    []*Watch
+	running    sync.WaitGroup
+	closer     sync.Once
 }
 
 func (w *Watcher) Changes() <-chan ChangeEvent {
 	return w.changes
 }
 
+// Close stops all watches. The changes channel is closed once every watch has stopped.
 func (w *Watcher) Close() error {
 	w.killSwitch()
+
+	// taking the lock makes sure that no watch is being added while waiting for the running ones to stop.
+	w.lock.Lock()
+	w.watches = nil
+	w.lock.Unlock()
+
+	w.running.Wait()
+	w.closer.Do(func() { close(w.changes) })
 	return nil
 }
 
-func NewWatcher(client *Client) *Watcher {
+func NewWatcher(source StatusSource, state *StateStore) *Watcher {
 	log.Debug("Initializing the checker...")
 	ctx, cancel := context.WithCancel(context.Background())
 	c := Watcher{
 		ctx:        ctx,
 		killSwitch: cancel,
-		client:     client,
+		source:     source,
+		state:      state,
 	}
 	c.changes = make(chan ChangeEvent, 10)
 
 	return &c
 }
 
-func (w *Watcher) AddWatch(service, namespace string, pollingInterval, warmupDuration time.Duration) error {
-	go func() {
-		log.Infof("Starting to watch %s", service)
-		t := time.NewTicker(pollingInterval)
-		defer t.Stop()
-		lastHotArtifact := Artifact{}
-		warmupArtifact := Artifact{}
-		cold := true
-
-		for {
-			select {
-			case <-t.C:
-				// fall out of the select and do the work.
-			case <-w.ctx.Done():
-				log.Infof("Stopping watch of %s", service)
-				close(w.changes)
-				return
-			}
-
-			a, err := w.GetArtifacts(service, namespace)
-			if err != nil {
-				log.Warnf("error when watching %s: %v", service, err)
-				continue
-			}
+// Watches returns the status of every watch, in the order that they were added.
+func (w *Watcher) Watches() []WatchStatus {
+	w.lock.Lock()
+	defer w.lock.Unlock()
 
-			if cold {
-				if lastHotArtifact.Equals(a.Dev) {
-					log.Debugf("Have already seen current dev artifact for %s. Skipping.", service)
-					continue
-				}
+	statuses := make([]WatchStatus, 0, len(w.watches))
+	for _, wt := range w.watches {
+		statuses = append(statuses, wt.Status())
+	}
+	return statuses
+}
 
-				if a.IsProdBehind() {
-					log.Infof("Warming up deploy %v for artifacts %+v", warmupDuration, a)
-					warmupArtifact = a.Dev
-					<-time.After(warmupDuration)
-					cold = false
-					continue
-				}
-			}
+// PauseWatch stops checking the service for new releases until it is resumed. All promotions of the service are paused.
+func (w *Watcher) PauseWatch(service string) error {
+	watches, err := w.find(service)
+	if err != nil {
+		return err
+	}
+	for _, wt := range watches {
+		wt.Pause()
+	}
+	return nil
+}
 
-			if warmupArtifact.Equals(a.Dev) && a.IsProdBehind() {
-				log.Infof("Sending event for possible upgrade of %s prod (%s) to dev artifact (%s)", service, a.Prod.Name, a.Dev.Name)
+// ResumeWatch starts checking a paused service for new releases again.
+func (w *Watcher) ResumeWatch(service string) error {
+	watches, err := w.find(service)
+	if err != nil {
+		return err
+	}
+	for _, wt := range watches {
+		wt.Resume()
+	}
+	return nil
+}
 
-				w.changes <- ChangeEvent{
-					Service:  a.Service,
-					Artifact: a.Dev.Name,
-					Author:   a.Dev.Author,
-				}
-			}
+// RefreshWatch makes the watches of the service check for new releases right away. This is intended to be called when
+// a release of the service is pushed from outside, so that polling can be kept as a slower fallback. If environment is
+// set, only the promotions to or from that environment are refreshed.
+func (w *Watcher) RefreshWatch(service, environment string) error {
+	watches, err := w.find(service)
+	if err != nil {
+		return err
+	}
 
-			// regardless of if the change was actually sent or not, we were in the hot state, and should record both
-			// lastHotArtifact, and move back to the cold state. Either the change event was sent, or then the state
-			// of dev/prod changed.
-			lastHotArtifact = warmupArtifact
-			cold = true
+	refreshed := false
+	for _, wt := range watches {
+		p := wt.Status().Promotion
+		if environment == "" || environment == p.From || environment == p.To {
+			wt.Refresh()
+			refreshed = true
 		}
-	}()
-
+	}
+	if !refreshed {
+		return fmt.Errorf("%w: %v in %v", ErrNotWatched, service, environment)
+	}
 	return nil
 }
 
-func (w *Watcher) GetArtifacts(service, namespace string) (Artifacts, error) {
-	type statusPayload struct {
-		Environments []struct {
-			Artifact
-			Environment string `json:"name"`
-		} `json:"environments"`
-	}
-
-	req, err := w.client.NewStatusRequest(service, namespace)
+// RemoveWatch stops watching the service. All promotions of the service are removed, and the function blocks until
+// they have all stopped.
+func (w *Watcher) RemoveWatch(service string) error {
+	watches, err := w.find(service)
 	if err != nil {
-		return Artifacts{}, err
+		return err
 	}
+	for _, wt := range watches {
+		wt.Stop()
+	}
+	return nil
+}
 
-	status := statusPayload{}
-	w.client.Do(req, &status)
-	if err != nil {
-		return Artifacts{}, err
+func (w *Watcher) find(service string) ([]*Watch, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	var found []*Watch
+	for _, wt := range w.watches {
+		if wt.Status().Service == service {
+			found = append(found, wt)
+		}
+	}
+	if len(found) == 0 {
+		return nil, fmt.Errorf("%w: %v", ErrNotWatched, service)
 	}
+	return found, nil
+}
+
+func (w *Watcher) remove(wt *Watch) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
 
-	a := Artifacts{
-		Service: service,
+	for i, candidate := range w.watches {
+		if candidate == wt {
+			w.watches = append(w.watches[:i:i], w.watches[i+1:]...)
+			return
+		}
 	}
+}
 
-	for _, env := range status.Environments {
-		switch env.Environment {
-		case "dev":
-			a.Dev = env.Artifact
-		case "prod":
-			a.Prod = env.Artifact
+// AddWatch starts watching the service for releases that can be promoted. Only a single watch can exist for every
+// service and promotion.
+func (w *Watcher) AddWatch(service, namespace string, promotion Promotion, pollingInterval, warmupDuration time.Duration) (*Watch, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.ctx.Err() != nil {
+		return nil, fmt.Errorf("watcher is closed")
+	}
+	for _, wt := range w.watches {
+		s := wt.Status()
+		if s.Service == service && s.Promotion == promotion {
+			return nil, fmt.Errorf("%v (%v) is already watched", service, promotion)
 		}
 	}
 
-	return a, nil
-}
+	ctx, cancel := context.WithCancel(w.ctx)
+	wt := &Watch{
+		status: WatchStatus{
+			Service:   service,
+			Namespace: namespace,
+			Promotion: promotion,
+		},
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		refresh: make(chan struct{}, 1),
+		watcher: w,
+	}
+	w.watches = append(w.watches, wt)
 
-type Notifier interface {
-	Alert(service, author string)
-	Success()
-	Failure()
-	Reset()
-}
+	w.running.Add(1)
+	go func() {
+		defer w.running.Done()
+		defer close(wt.done)
+		w.watch(ctx, wt, pollingInterval, warmupDuration)
+	}()
 
-type Deployer interface {
-	Promote(service, artifact string) error
+	return wt, nil
 }
 
-func ChangeListener(
-	ctx context.Context,
-	notifier Notifier,
-	promoter Deployer,
-	alertSeconds int,
-	changes <-chan ChangeEvent,
-	confirm <-chan bool,
-) {
-	alertDuration := 45 * time.Second
-	if alertSeconds > 0 {
-		alertDuration = time.Duration(alertSeconds) * time.Second
-	}
+func (w *Watcher) watch(ctx context.Context, wt *Watch, pollingInterval, warmupDuration time.Duration) {
+	status := wt.Status()
+	service, namespace, promotion := status.Service, status.Namespace, status.Promotion
 
-	log.Infof("%v used as alert duration", alertDuration)
+	log.Infof("Starting to watch %s (%v)", service, promotion)
+	t := time.NewTicker(pollingInterval)
+	defer t.Stop()
+	lastHotArtifact := Artifact{}
+	warmupArtifact := Artifact{}
+	cold := true
 
 	for {
 		select {
+		case <-t.C:
+			// fall out of the select and do the work.
+		case <-wt.refresh:
+			log.Debugf("Refreshing watch of %s (%v)", service, promotion)
 		case <-ctx.Done():
-			break
-		case e := <-changes:
-			log.Infof("Service %s changed. Waiting for confirmation!", e.Service)
-			notifier.Alert(e.Service, e.Author)
+			log.Infof("Stopping watch of %s (%v)", service, promotion)
+			return
+		}
 
-			select {
-			case confirmed := <-confirm:
-				if confirmed {
-					log.Infof("Promoting %s for service %s to production.", e.Artifact, e.Service)
-					err := promoter.Promote(e.Service, e.Artifact)
-					if err != nil {
-						log.Warn("Unable to trigger deploy: ", err)
-						lcd.Print("TRIGGER FAILED", "")
-						notifier.Failure()
-						<-time.After(5 * time.Second)
-					} else {
-						notifier.Success()
-					}
+		if wt.isPaused() {
+			log.Debugf("Watch of %s is paused.", service)
+			cold = true
+			continue
+		}
+
+		a, err := w.GetArtifacts(service, namespace, promotion)
+		if err != nil {
+			metrics.StatusPolls.WithLabelValues(service, metrics.OutcomeError).Inc()
+			log.Warnf("error when watching %s: %v", service, err)
+			continue
+		}
+		metrics.StatusPolls.WithLabelValues(service, metrics.OutcomeSuccess).Inc()
+		wt.checked(a)
+
+		if cold {
+			if lastHotArtifact.Equals(a.Source) {
+				log.Debugf("Have already seen current %s artifact for %s. Skipping.", promotion.From, service)
+				continue
+			}
+
+			state := w.state.Get(service, promotion)
+			if state.LastSkipped == a.Source.Name {
+				log.Debugf("Current %s artifact for %s has been skipped before. Skipping.", promotion.From, service)
+				lastHotArtifact = a.Source
+				continue
+			}
+
+			// after a restart, pick up from the artifact that was last alerted about before the restart.
+			if lastHotArtifact.Name == "" && state.LastSeen == a.Source.Name {
+				log.Debugf("Current %s artifact for %s was alerted about before. Skipping.", promotion.From, service)
+				lastHotArtifact = a.Source
+				continue
+			}
+
+			if a.IsTargetBehind() {
+				log.Infof("Warming up deploy %v for artifacts %+v", warmupDuration, a)
+				warmupArtifact = a.Source
+				select {
+				case <-time.After(warmupDuration):
+				case <-ctx.Done():
 				}
-			case <-time.After(alertDuration):
-				log.Info("Confirmation timed out.")
+				cold = false
+				// check again as soon as the warmup is done, rather than waiting for the next polling interval.
+				wt.Refresh()
+				continue
 			}
-			notifier.Reset()
 		}
+
+		if warmupArtifact.Equals(a.Source) && a.IsTargetBehind() {
+			log.Infof("Sending event for possible upgrade of %s %s (%s) to %s artifact (%s)", service, promotion.To, a.Target.Name, promotion.From, a.Source.Name)
+
+			e := ChangeEvent{
+				Service:   a.Service,
+				Namespace: namespace,
+				Promotion: promotion,
+				Artifact:  a.Source.Name,
+				Author:    a.Source.Author,
+				Replaced:  a.Target.Name,
+			}
+			select {
+			case w.changes <- e:
+			case <-ctx.Done():
+				log.Infof("Stopping watch of %s (%v)", service, promotion)
+				return
+			}
+		}
+
+		// regardless of if the change was actually sent or not, we were in the hot state, and should record both
+		// lastHotArtifact, and move back to the cold state. Either the change event was sent, or then the state
+		// of dev/prod changed.
+		lastHotArtifact = warmupArtifact
+		cold = true
 	}
 }
+
+// GetArtifacts fetches the artifacts currently running in the source and target environments of the given promotion.
+func (w *Watcher) GetArtifacts(service, namespace string, promotion Promotion) (Artifacts, error) {
+	return w.source.Artifacts(w.ctx, service, namespace, promotion)
+}
@@ -63,9 +63,9 @@ func TestWatch(t *testing.T) {
 	defer s.Close()
 
 	c := NewClient(s.URL, "arst", "me@local.com")
-	w := NewWatcher(c)
+	w := NewWatcher(c, NewStateStore())
 	defer w.Close()
-	err = w.AddWatch("some-service", "prod", time.Millisecond, 5*time.Millisecond)
+	_, err = w.AddWatch("some-service", "prod", DefaultPromotion, time.Millisecond, 5*time.Millisecond)
 	assert.NoError(t, err)
 
 	select {
@@ -96,9 +96,9 @@ func TestWatch_OnlyReportsChangeOnce(t *testing.T) {
 	defer s.Close()
 
 	c := NewClient(s.URL, "arst", "me@local.com")
-	w := NewWatcher(c)
+	w := NewWatcher(c, NewStateStore())
 	defer w.Close()
-	err = w.AddWatch("some-service", "prod", time.Millisecond, 5*time.Millisecond)
+	_, err = w.AddWatch("some-service", "prod", DefaultPromotion, time.Millisecond, 5*time.Millisecond)
 	assert.NoError(t, err)
 
 	select {
@@ -142,9 +142,9 @@ func TestWatch_AbandonHotAfterExternalChange(t *testing.T) {
 	defer s.Close()
 
 	c := NewClient(s.URL, "arst", "me@local.com")
-	w := NewWatcher(c)
+	w := NewWatcher(c, NewStateStore())
 	defer w.Close()
-	err = w.AddWatch("some-service", "prod", time.Millisecond, 5*time.Millisecond)
+	_, err = w.AddWatch("some-service", "prod", DefaultPromotion, time.Millisecond, 5*time.Millisecond)
 	assert.NoError(t, err)
 
 	select {
@@ -155,9 +155,184 @@ func TestWatch_AbandonHotAfterExternalChange(t *testing.T) {
 	}
 }
 
+func TestWatch_IgnoresSkippedArtifact(t *testing.T) {
+	setDebug()
+
+	tmpl, err := template.New("status").Parse(statusTemplate)
+	require.NoError(t, err)
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		tmpl.Execute(w, statusData{
+			DevArtifact:  "master-04169c5a19-a9c84eb8ff",
+			DevTime:      1674119917135,
+			ProdArtifact: "master-6831b4ba23-5876ec33b0",
+			ProdTime:     1674119916510,
+		})
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	state := NewStateStore()
+	state.Update("some-service", DefaultPromotion, func(state *ServiceState) {
+		state.LastSkipped = "master-04169c5a19-a9c84eb8ff"
+	})
+
+	c := NewClient(s.URL, "arst", "me@local.com")
+	w := NewWatcher(c, state)
+	defer w.Close()
+	_, err = w.AddWatch("some-service", "prod", DefaultPromotion, time.Millisecond, 5*time.Millisecond)
+	assert.NoError(t, err)
+
+	select {
+	case <-w.Changes():
+		t.Fatal("a skipped artifact should not be reported again")
+	case <-time.After(250 * time.Millisecond):
+	}
+}
+
+func TestWatch_ResumesFromLastSeenArtifact(t *testing.T) {
+	setDebug()
+
+	tmpl, err := template.New("status").Parse(statusTemplate)
+	require.NoError(t, err)
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		tmpl.Execute(w, statusData{
+			DevArtifact:  "master-04169c5a19-a9c84eb8ff",
+			DevTime:      1674119917135,
+			ProdArtifact: "master-6831b4ba23-5876ec33b0",
+			ProdTime:     1674119916510,
+		})
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	state := NewStateStore()
+	state.Update("some-service", DefaultPromotion, func(state *ServiceState) {
+		state.LastSeen = "master-04169c5a19-a9c84eb8ff"
+	})
+
+	c := NewClient(s.URL, "arst", "me@local.com")
+	w := NewWatcher(c, state)
+	defer w.Close()
+	_, err = w.AddWatch("some-service", "prod", DefaultPromotion, time.Millisecond, 5*time.Millisecond)
+	assert.NoError(t, err)
+
+	select {
+	case <-w.Changes():
+		t.Fatal("an artifact alerted about before a restart should not be reported again")
+	case <-time.After(250 * time.Millisecond):
+	}
+}
+
+func TestWatch_Refresh(t *testing.T) {
+	setDebug()
+
+	tmpl, err := template.New("status").Parse(statusTemplate)
+	require.NoError(t, err)
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		tmpl.Execute(w, statusData{
+			DevArtifact:  "master-04169c5a19-a9c84eb8ff",
+			DevTime:      1674119917135,
+			ProdArtifact: "master-6831b4ba23-5876ec33b0",
+			ProdTime:     1674119916510,
+		})
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	c := NewClient(s.URL, "arst", "me@local.com")
+	w := NewWatcher(c, NewStateStore())
+	defer w.Close()
+	_, err = w.AddWatch("some-service", "prod", DefaultPromotion, time.Hour, 5*time.Millisecond)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, w.RefreshWatch("some-service", "staging"), ErrNotWatched)
+	require.NoError(t, w.RefreshWatch("some-service", "dev"))
+
+	select {
+	case e := <-w.Changes():
+		assert.Equal(t, "master-04169c5a19-a9c84eb8ff", e.Artifact)
+	case <-time.After(250 * time.Millisecond):
+		t.Fatal("timed out waiting for change event")
+	}
+}
+
+func TestRemoveWatch(t *testing.T) {
+	c := NewClient("http://localhost", "arst", "me@local.com")
+	w := NewWatcher(c, NewStateStore())
+	defer w.Close()
+
+	for _, service := range []string{"some-service", "other-service"} {
+		for _, p := range []Promotion{{From: "dev", To: "staging"}, {From: "staging", To: "prod"}} {
+			_, err := w.AddWatch(service, "", p, time.Hour, time.Hour)
+			require.NoError(t, err)
+		}
+	}
+
+	require.NoError(t, w.RemoveWatch("some-service"))
+	watches := w.Watches()
+	require.Len(t, watches, 2)
+	assert.Equal(t, "other-service", watches[0].Service)
+	assert.Equal(t, "other-service", watches[1].Service)
+
+	assert.ErrorIs(t, w.RemoveWatch("some-service"), ErrNotWatched)
+}
+
+func TestAddWatch_Duplicate(t *testing.T) {
+	c := NewClient("http://localhost", "arst", "me@local.com")
+	w := NewWatcher(c, NewStateStore())
+	defer w.Close()
+
+	_, err := w.AddWatch("some-service", "", DefaultPromotion, time.Hour, time.Hour)
+	require.NoError(t, err)
+	_, err = w.AddWatch("some-service", "", DefaultPromotion, time.Hour, time.Hour)
+	assert.Error(t, err)
+}
+
+func TestWatchHandle(t *testing.T) {
+	c := NewClient("http://localhost", "arst", "me@local.com")
+	w := NewWatcher(c, NewStateStore())
+	defer w.Close()
+
+	wt, err := w.AddWatch("some-service", "", DefaultPromotion, time.Hour, time.Hour)
+	require.NoError(t, err)
+
+	wt.Pause()
+	assert.True(t, w.Watches()[0].Paused)
+	wt.Resume()
+	assert.False(t, w.Watches()[0].Paused)
+
+	wt.Stop()
+	assert.Empty(t, w.Watches())
+}
+
+func TestClose_ClosesChangesOnce(t *testing.T) {
+	c := NewClient("http://localhost", "arst", "me@local.com")
+	w := NewWatcher(c, NewStateStore())
+
+	for _, service := range []string{"first-service", "second-service", "third-service"} {
+		_, err := w.AddWatch(service, "", DefaultPromotion, time.Millisecond, time.Millisecond)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, w.Close())
+	require.NoError(t, w.Close())
+
+	_, open := <-w.Changes()
+	assert.False(t, open)
+
+	_, err := w.AddWatch("late-service", "", DefaultPromotion, time.Hour, time.Hour)
+	assert.Error(t, err)
+}
+
 func TestReleaseRequestBody(t *testing.T) {
 	c := NewClient("localhost", "arst", "me@local.com")
-	req, err := c.NewPromoteRequest("test-service", "the-dev-artifact")
+	req, err := c.NewPromoteRequest("test-service", "the-dev-artifact", DefaultPromotion)
 
 	require.NoError(t, err)
 	require.NotNil(t, req)
@@ -165,6 +340,39 @@ func TestReleaseRequestBody(t *testing.T) {
 	require.Equal(t, `{"service":"test-service","environment":"prod","artifactId":"the-dev-artifact","committerName":"Surveyor deployer","committerEmail":"me@local.com","intent":{"type":"Promote","promote":{"fromEnvironment":"dev"}}}`, string(body))
 }
 
+func TestReleaseRequestBody_CustomPromotion(t *testing.T) {
+	c := NewClient("localhost", "arst", "me@local.com")
+	req, err := c.NewPromoteRequest("test-service", "the-staging-artifact", Promotion{From: "staging", To: "prod"})
+
+	require.NoError(t, err)
+	require.NotNil(t, req)
+	body, _ := io.ReadAll(req.Body)
+	require.Equal(t, `{"service":"test-service","environment":"prod","artifactId":"the-staging-artifact","committerName":"Surveyor deployer","committerEmail":"me@local.com","intent":{"type":"Promote","promote":{"fromEnvironment":"staging"}}}`, string(body))
+}
+
+func TestGetArtifacts_CustomPromotion(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		w.Write([]byte(`{"environments":[
+			{"name":"dev","tag":"dev-artifact","date":3},
+			{"name":"staging","tag":"staging-artifact","date":2},
+			{"name":"prod","tag":"prod-artifact","date":1}
+		]}`))
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	w := NewWatcher(NewClient(s.URL, "arst", "me@local.com"), NewStateStore())
+	defer w.Close()
+	a, err := w.GetArtifacts("some-service", "", Promotion{From: "staging", To: "prod"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "staging-artifact", a.Source.Name)
+	assert.Equal(t, "prod-artifact", a.Target.Name)
+	assert.Equal(t, "prod", a.Promotion.To)
+	assert.True(t, a.IsTargetBehind())
+}
+
 func setDebug() {
 	log.SetFormatter(&log.TextFormatter{
 		TimestampFormat: "2006-01-02T15:04:05.000Z07:00",
@@ -2,9 +2,8 @@ package lcd
 
 import (
 	"fmt"
-	"periph.io/x/conn/v3/gpio"
 	"strings"
-	"time"
+	"unicode/utf8"
 )
 
 type Line byte
@@ -20,50 +19,51 @@ func (l Line) String() string {
 }
 
 const (
-	registerSelectionPin = "GPIO4"
-	clockEdgePin         = "GPIO17"
-	data4Pin             = "GPIO25"
-	data5Pin             = "GPIO22"
-	data6Pin             = "GPIO23"
-	data7Pin             = "GPIO24"
-
 	Line1 Line = 0x80
 	Line2 Line = 0xC0
 
-	lineWidth   = 16
-	character   = gpio.High
-	command     = gpio.Low
-	signalPulse = 500000 * time.Nanosecond
-	signalDelay = 500000 * time.Nanosecond
+	lineWidth = 16
 )
 
-var (
-	registerSelection gpio.PinIO
-	clockEdge         gpio.PinIO
-	dataPins          [4]gpio.PinIO
-)
+// Display is a character display with two lines of 16 characters.
+type Display interface {
+	// Println shows the message on the line, replacing whatever was shown on it. Messages longer than the line are cut
+	// short.
+	Println(l Line, msg string)
+	Clear(l Line)
+}
 
-// Center aligns a string to the 16 character window size. If the string is longer than 16 characters, it will be
-// truncated to fit.
+// Center aligns a string to the 16 character window size. A string that is longer than 16 characters is returned as it
+// is, to be cut short or scrolled by the display.
 func Center(msg string) string {
-	if len(msg) >= 16 {
-		return msg[:16]
+	n := utf8.RuneCountInString(msg)
+	if n >= 16 {
+		return msg
 	}
-	leftPad := (16 - len(msg)) / 2
+	leftPad := (16 - n) / 2
 	return fmt.Sprintf("%v%v", strings.Repeat(" ", leftPad), msg)
 }
 
-func Reset() {
-	Println(Line1, "Surveyor deploy")
-	Clear(Line2)
+// cut shortens the message to the width of a line, counting characters rather than bytes.
+func cut(msg string) string {
+	r := []rune(msg)
+	if len(r) > lineWidth {
+		return string(r[:lineWidth])
+	}
+	return msg
+}
+
+func Reset(d Display) {
+	d.Println(Line1, "Surveyor deploy")
+	d.Clear(Line2)
 }
 
-func ClearAll() {
-	Clear(Line1)
-	Clear(Line2)
+func ClearAll(d Display) {
+	d.Clear(Line1)
+	d.Clear(Line2)
 }
 
-func Print(line1, line2 string) {
-	Println(Line1, Center(line1))
-	Println(Line2, Center(line2))
+func Print(d Display, line1, line2 string) {
+	d.Println(Line1, Center(line1))
+	d.Println(Line2, Center(line2))
 }
@@ -0,0 +1,104 @@
+package lcd
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestPrint(t *testing.T) {
+	r := NewRecorder()
+	Print(r, "service", "a-very-long-author-name")
+	assert.Equal(t, "    service", r.Line(Line1))
+	assert.Equal(t, "a-very-long-auth", r.Line(Line2))
+
+	Reset(r)
+	assert.Equal(t, "Surveyor deploy", r.Line(Line1))
+	assert.Equal(t, "", r.Line(Line2))
+	assert.Equal(t, []string{"L1:     service", "L2: a-very-long-auth", "L1: Surveyor deploy", "L2: "}, r.History())
+}
+
+func TestTerminal(t *testing.T) {
+	out := &bytes.Buffer{}
+	d := NewTerminal(out)
+	d.Println(Line1, "first line that is too long")
+	d.Clear(Line2)
+	assert.Equal(t, "Print line L1: \"first line that \"\nClear line L2\n", out.String())
+}
+
+func TestMarquee(t *testing.T) {
+	r := NewRecorder()
+	m := NewMarquee(r, time.Millisecond)
+	defer m.Close()
+
+	m.Println(Line1, "payment-gateway-reconciler")
+	m.Println(Line2, "fits")
+
+	// the long line scrolls until it has come around to its start again, while the short one stays as it is.
+	cycle := scrollPause + len("payment-gateway-reconciler") + len(scrollGap)
+	assert.Equal(t, time.Duration(cycle)*time.Millisecond, m.Cycle("payment-gateway-reconciler"))
+	require.Eventually(t, func() bool {
+		return len(r.History()) > cycle+2
+	}, time.Second, time.Millisecond)
+
+	m.Println(Line1, "short")
+	history := r.History()
+	assert.Equal(t, "L1: payment-gateway-", history[0])
+	assert.Equal(t, "L2: fits", history[1])
+	assert.Contains(t, history, "L1: ayment-gateway-r")
+	assert.Contains(t, history, "L1: ciler   payment-")
+	assert.NotContains(t, history[2:], "L2: fits")
+
+	// once the line fits, it stops scrolling.
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, "short", r.Line(Line1))
+	assert.Equal(t, time.Duration(0), m.Cycle("short"))
+}
+
+func TestMarquee_Runes(t *testing.T) {
+	r := NewRecorder()
+	m := NewMarquee(r, time.Millisecond)
+	defer m.Close()
+
+	// a line of 16 characters fits, even though it is longer than 16 bytes.
+	m.Println(Line1, "Björn-Åke And 9s")
+	assert.Equal(t, time.Duration(0), m.Cycle("Björn-Åke And 9s"))
+
+	long := "Åsa Öberg-Lindqvist på väg"
+	m.Println(Line2, long)
+	cycle := scrollPause + utf8.RuneCountInString(long) + len(scrollGap)
+	assert.Equal(t, time.Duration(cycle)*time.Millisecond, m.Cycle(long))
+	require.Eventually(t, func() bool {
+		return len(r.History()) > cycle+2
+	}, time.Second, time.Millisecond)
+
+	history := r.History()
+	assert.Equal(t, "L1: Björn-Åke And 9s", history[0])
+	assert.Equal(t, "L2: Åsa Öberg-Lindqv", history[1])
+	assert.Contains(t, history, "L2: sa Öberg-Lindqvi")
+	for _, h := range history[1:] {
+		require.True(t, strings.HasPrefix(h, "L2: "), h)
+		assert.True(t, utf8.ValidString(h), h)
+		assert.Equal(t, lineWidth, utf8.RuneCountInString(strings.TrimPrefix(h, "L2: ")), h)
+	}
+}
+
+func TestRecorder_CutsRunes(t *testing.T) {
+	r := NewRecorder()
+	r.Println(Line1, "Åsa Öberg-Lindqvist")
+	assert.Equal(t, "Åsa Öberg-Lindqv", r.Line(Line1))
+
+	out := &bytes.Buffer{}
+	NewTerminal(out).Println(Line1, "Åsa Öberg-Lindqvist")
+	assert.Equal(t, "Print line L1: \"Åsa Öberg-Lindqv\"\n", out.String())
+}
+
+func TestToROM(t *testing.T) {
+	assert.Equal(t, "plain           ", string(toROM("plain")))
+	assert.Equal(t, "a?b?c?d         ", string(toROM("a\\b~cåd")))
+	assert.Equal(t, "exactly-sixteen-", string(toROM("exactly-sixteen-and-more")))
+}
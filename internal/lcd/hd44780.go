@@ -0,0 +1,103 @@
+//go:build pi
+
+package lcd
+
+import (
+	"github.com/sirupsen/logrus"
+	"periph.io/x/conn/v3/gpio"
+	"periph.io/x/conn/v3/gpio/gpioreg"
+	"periph.io/x/host/v3"
+	"sync"
+	"time"
+)
+
+const (
+	registerSelectionPin = "GPIO4"
+	clockEdgePin         = "GPIO17"
+	data4Pin             = "GPIO25"
+	data5Pin             = "GPIO22"
+	data6Pin             = "GPIO23"
+	data7Pin             = "GPIO24"
+
+	character   = gpio.High
+	command     = gpio.Low
+	signalPulse = 500000 * time.Nanosecond
+	signalDelay = 500000 * time.Nanosecond
+)
+
+func init() {
+	if _, err := host.Init(); err != nil {
+		logrus.Fatalln("Unable to initialize periph: ", err)
+	}
+}
+
+// New returns the HD44780 display of the big-switch.
+func New() Display {
+	return NewHD44780()
+}
+
+// HD44780 is an HD44780 character display, driven over GPIO in 4-bit mode.
+type HD44780 struct {
+	// lock keeps the bytes of concurrent writes from being interleaved.
+	lock              sync.Mutex
+	registerSelection gpio.PinIO
+	clockEdge         gpio.PinIO
+	dataPins          [4]gpio.PinIO
+}
+
+// NewHD44780 initializes the pins of the display, and sets it up for writing.
+func NewHD44780() *HD44780 {
+	logrus.Infoln("Initializing LCD")
+	d := &HD44780{
+		registerSelection: gpioreg.ByName(registerSelectionPin),
+		clockEdge:         gpioreg.ByName(clockEdgePin),
+		dataPins: [4]gpio.PinIO{
+			gpioreg.ByName(data4Pin),
+			gpioreg.ByName(data5Pin),
+			gpioreg.ByName(data6Pin),
+			gpioreg.ByName(data7Pin),
+		},
+	}
+
+	d.sendByte(0x33, command)
+	d.sendByte(0x32, command)
+	d.sendByte(0x28, command)
+	d.sendByte(0x0C, command)
+	d.sendByte(0x06, command)
+	d.sendByte(0x01, command)
+	return d
+}
+
+func (d *HD44780) sendByte(bits byte, mode gpio.Level) {
+	d.registerSelection.Out(mode)
+	d.pulseByte(bits, 0x10)
+	d.pulseByte(bits, 0x01)
+}
+
+func (d *HD44780) pulseByte(bits, mask byte) {
+	for i, pin := range d.dataPins {
+		pin.Out(gpio.Low)
+		if bits&(mask<<uint(i)) != 0 {
+			pin.Out(gpio.High)
+		}
+	}
+	time.Sleep(signalDelay)
+	d.clockEdge.Out(gpio.High)
+	time.Sleep(signalPulse)
+	d.clockEdge.Out(gpio.Low)
+	time.Sleep(signalDelay)
+}
+
+func (d *HD44780) Println(l Line, msg string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.sendByte(byte(l), command)
+	for _, c := range toROM(msg) {
+		d.sendByte(c, character)
+	}
+}
+
+func (d *HD44780) Clear(l Line) {
+	d.Println(l, "")
+}
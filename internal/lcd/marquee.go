@@ -0,0 +1,130 @@
+package lcd
+
+import (
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultScrollInterval is the time between each step of a scrolling line.
+	DefaultScrollInterval = 300 * time.Millisecond
+	// scrollPause is the number of steps that the start of a scrolling line is held for, before it starts moving.
+	scrollPause = 4
+	// scrollGap separates the end of a scrolling line from its start as it comes around again.
+	scrollGap = "   "
+)
+
+// Marquee is a display that scrolls lines that are too long to fit, on a goroutine of its own. Lines that fit are
+// passed on to the underlying display as they are. A scrolling line is redrawn a character to the left at every step,
+// since shifting the display itself, as the HD44780 can, would move both lines together.
+type Marquee struct {
+	display  Display
+	interval time.Duration
+	lock     sync.Mutex
+	// scrolling are the lines that are currently scrolling.
+	scrolling map[Line]*scrollingLine
+	stop      chan struct{}
+}
+
+type scrollingLine struct {
+	text string
+	// loop is the line followed by the gap and the line again, in runes, so that every window is a plain slice of it.
+	loop []rune
+	step int
+}
+
+// NewMarquee creates a marquee scrolling the long lines of the display, a character every interval.
+func NewMarquee(d Display, interval time.Duration) *Marquee {
+	m := &Marquee{
+		display:   d,
+		interval:  interval,
+		scrolling: make(map[Line]*scrollingLine),
+		stop:      make(chan struct{}),
+	}
+	go m.run()
+	return m
+}
+
+// Close stops the scrolling. Lines that are scrolling are left as they are.
+func (m *Marquee) Close() {
+	close(m.stop)
+}
+
+// Println shows the message on the line, scrolling it if it is too long. Showing the same long message again keeps it
+// scrolling from where it was.
+func (m *Marquee) Println(l Line, msg string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if utf8.RuneCountInString(msg) <= lineWidth {
+		delete(m.scrolling, l)
+		m.display.Println(l, msg)
+		return
+	}
+	if s, ok := m.scrolling[l]; ok && s.text == msg {
+		return
+	}
+	s := &scrollingLine{text: msg, loop: []rune(msg + scrollGap + msg)}
+	m.scrolling[l] = s
+	m.display.Println(l, s.window())
+}
+
+func (m *Marquee) Clear(l Line) {
+	m.Println(l, "")
+}
+
+// Cycle returns the time it takes to scroll the message around once, or 0 if it fits on the line without scrolling.
+func (m *Marquee) Cycle(msg string) time.Duration {
+	n := utf8.RuneCountInString(msg)
+	if n <= lineWidth {
+		return 0
+	}
+	return time.Duration(scrollPause+n+len(scrollGap)) * m.interval
+}
+
+func (m *Marquee) run() {
+	tick := time.NewTicker(m.interval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-tick.C:
+		}
+
+		m.lock.Lock()
+		for l, s := range m.scrolling {
+			s.advance()
+			m.display.Println(l, s.window())
+		}
+		m.lock.Unlock()
+	}
+}
+
+// advance the line a step, and start over once it has come around to its start again.
+func (s *scrollingLine) advance() {
+	s.step++
+	if s.step >= scrollPause+utf8.RuneCountInString(s.text)+len(scrollGap) {
+		s.step = 0
+	}
+}
+
+// window returns the part of the line that is shown at the current step.
+func (s *scrollingLine) window() string {
+	offset := s.step - scrollPause
+	if offset < 0 {
+		offset = 0
+	}
+	return string(s.loop[offset : offset+lineWidth])
+}
+
+// ScrollTime returns the time it takes for the display to scroll the message around once. It is 0 if the message fits
+// on the line, or if the display does not scroll.
+func ScrollTime(d Display, msg string) time.Duration {
+	if m, ok := d.(*Marquee); ok {
+		return m.Cycle(msg)
+	}
+	return 0
+}
@@ -0,0 +1,46 @@
+package lcd
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Recorder is a display that keeps what is shown on it, so that tests can check the output.
+type Recorder struct {
+	lock    sync.Mutex
+	lines   map[Line]string
+	history []string
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{lines: make(map[Line]string)}
+}
+
+func (r *Recorder) Println(l Line, msg string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	msg = cut(msg)
+	r.lines[l] = msg
+	r.history = append(r.history, fmt.Sprintf("%v: %v", l, msg))
+}
+
+func (r *Recorder) Clear(l Line) {
+	r.Println(l, "")
+}
+
+// Line returns what is currently shown on the line.
+func (r *Recorder) Line(l Line) string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.lines[l]
+}
+
+// History returns every line that has been shown, oldest first, as the line followed by the message, like "L1: msg".
+func (r *Recorder) History() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return append([]string(nil), r.history...)
+}
@@ -0,0 +1,20 @@
+package lcd
+
+import "strings"
+
+// toROM maps the message onto the characters of the HD44780 character ROM, padded or cut to the width of a line. The
+// ROM only shares the printable ASCII characters up to '}', except for '\', so anything else is shown as '?' instead
+// of as whatever the ROM has in its place.
+func toROM(msg string) []byte {
+	line := make([]byte, 0, lineWidth)
+	for _, r := range msg {
+		if len(line) == lineWidth {
+			break
+		}
+		if r < ' ' || r > '}' || r == '\\' {
+			r = '?'
+		}
+		line = append(line, byte(r))
+	}
+	return append(line, strings.Repeat(" ", lineWidth-len(line))...)
+}
@@ -0,0 +1,32 @@
+package lcd
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Terminal is a display that writes every line that is shown to a writer, like standard output.
+type Terminal struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+func NewTerminal(w io.Writer) *Terminal {
+	return &Terminal{w: w}
+}
+
+func (t *Terminal) Println(l Line, msg string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	msg = cut(msg)
+	fmt.Fprintf(t.w, "Print line %v: \"%v\"\n", l, msg)
+}
+
+func (t *Terminal) Clear(l Line) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	fmt.Fprintf(t.w, "Clear line %v\n", l)
+}
@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+)
+
+const namespace = "bigswitch"
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+)
+
+var (
+	// StatusPolls counts the status checks of watched services, by service and outcome.
+	StatusPolls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "status_polls_total",
+		Help:      "Number of status polls of watched services.",
+	}, []string{"service", "outcome"})
+
+	// ReleaseManagerResponses counts the responses received from release-manager, by status code. Requests that did not
+	// get a response at all are counted with the code "error".
+	ReleaseManagerResponses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "release_manager_responses_total",
+		Help:      "Number of responses from release-manager by status code.",
+	}, []string{"code"})
+
+	Alerts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "alerts_total",
+		Help:      "Number of release alerts raised.",
+	}, []string{"service"})
+
+	Confirmations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "confirmations_total",
+		Help:      "Number of release alerts that were confirmed.",
+	}, []string{"service"})
+
+	Skips = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "skips_total",
+		Help:      "Number of release alerts that were skipped.",
+	}, []string{"service"})
+
+	Timeouts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "timeouts_total",
+		Help:      "Number of release alerts that timed out without being confirmed.",
+	}, []string{"service"})
+
+	PromoteFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "promote_failures_total",
+		Help:      "Number of confirmed releases that could not be promoted.",
+	}, []string{"service"})
+
+	RolloutFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rollout_failures_total",
+		Help:      "Number of promoted releases that did not reach the target environment in time.",
+	}, []string{"service"})
+
+	Rollbacks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rollbacks_total",
+		Help:      "Number of confirmed rollbacks.",
+	}, []string{"service"})
+
+	FreezeOverrides = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "freeze_overrides_total",
+		Help:      "Number of releases promoted by overriding a freeze.",
+	}, []string{"service"})
+
+	// ConfirmationDelay is the time from an alert being raised until it was confirmed.
+	ConfirmationDelay = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "confirmation_delay_seconds",
+		Help:      "Time from a release alert being raised until it was confirmed.",
+		Buckets:   []float64{1, 2, 5, 10, 15, 20, 30, 45, 60, 90, 120, 300},
+	}, []string{"service"})
+)
+
+// Handler serves all registered metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,124 @@
+package neopixel
+
+import (
+	log "github.com/sirupsen/logrus"
+	"math"
+	"time"
+)
+
+// Frame is the color of every LED on the ring, clockwise from the first LED.
+type Frame []uint32
+
+// Animation draws the frame with the given step number, starting from 0, onto a cleared frame. It returns false once
+// the animation is over.
+type Animation func(f Frame, step int) bool
+
+func (f Frame) clear() {
+	for i := range f {
+		f[i] = 0
+	}
+}
+
+// set the color of the LED at the position, wrapping around the ring in both directions.
+func (f Frame) set(pos int, color uint32) {
+	n := len(f)
+	f[((pos%n)+n)%n] = color
+}
+
+// Animate plays the animation on the ring, drawing a new frame at every interval until the animation is over or it is
+// interrupted by another effect. It returns right away, and the ring is cleared once the animation stops.
+func (l *LedController) Animate(interval time.Duration, a Animation) {
+	l.animate(interval, a)
+}
+
+// animate plays the animation like Animate, and returns a channel that is closed once the animation has stopped.
+func (l *LedController) animate(interval time.Duration, a Animation) <-chan struct{} {
+	done := l.interruptor.Interrupt()
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		defer done()
+		defer l.clear()
+
+		frame := make(Frame, len(l.ws.Leds(0)))
+		tick := time.NewTicker(interval)
+		defer tick.Stop()
+		for step := 0; ; step++ {
+			if l.interruptor.IsInterrupted() {
+				log.Debug("Stopping animation: animation interrupted.")
+				return
+			}
+
+			frame.clear()
+			if !a(frame, step) {
+				return
+			}
+			if err := l.setFrame(frame); err != nil {
+				log.Debug("Stopping animation: ", err)
+				return
+			}
+
+			<-tick.C
+		}
+	}()
+
+	return stopped
+}
+
+func (l *LedController) setFrame(f Frame) error {
+	copy(l.ws.Leds(0), f)
+	return l.ws.Render()
+}
+
+// Spinner moves a block of length LEDs around the ring, one LED per step.
+func Spinner(color uint32, length int) Animation {
+	return func(f Frame, step int) bool {
+		for i := 0; i < length; i++ {
+			f.set(step+i, color)
+		}
+		return true
+	}
+}
+
+// Comet moves a single LED around the ring, one LED per step, followed by a tail of LEDs fading out behind it.
+func Comet(color uint32, tail int) Animation {
+	return func(f Frame, step int) bool {
+		f.set(step, color)
+		for i := 1; i <= tail; i++ {
+			f.set(step-i, withBrightness(color, uint32(100*(tail+1-i)/(tail+1))))
+		}
+		return true
+	}
+}
+
+// Arc lights up the part of the ring given by progress, from 0 for none of it to 1 for all of it, starting from the
+// first LED. Progress is checked for every frame, so the arc can grow or shrink while it is shown. An LED stays lit
+// until the part it covers has run out completely.
+func Arc(color uint32, progress func() float64) Animation {
+	return func(f Frame, _ int) bool {
+		p := math.Min(math.Max(progress(), 0), 1)
+		lit := int(math.Ceil(p * float64(len(f))))
+		for i := 0; i < lit; i++ {
+			f[i] = color
+		}
+		return true
+	}
+}
+
+// Segments divides the ring into one segment per color, separated by a dark LED when there is room for it.
+func Segments(colors []uint32) Animation {
+	return func(f Frame, _ int) bool {
+		n, m := len(f), len(colors)
+		for k, color := range colors {
+			start, end := k*n/m, (k+1)*n/m
+			if m > 1 && end-start > 1 {
+				end--
+			}
+			for i := start; i < end; i++ {
+				f[i] = color
+			}
+		}
+		return true
+	}
+}
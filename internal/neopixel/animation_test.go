@@ -0,0 +1,88 @@
+package neopixel
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// draw returns the frame with the given step of the animation, on a ring of n LEDs.
+func draw(a Animation, n, step int) Frame {
+	f := make(Frame, n)
+	a(f, step)
+	return f
+}
+
+func TestSpinner(t *testing.T) {
+	a := Spinner(ColorRed, 2)
+	assert.Equal(t, Frame{ColorRed, ColorRed, 0, 0, 0, 0}, draw(a, 6, 0))
+	assert.Equal(t, Frame{0, 0, 0, ColorRed, ColorRed, 0}, draw(a, 6, 3))
+	// the spinner wraps around to the start of the ring.
+	assert.Equal(t, Frame{ColorRed, 0, 0, 0, 0, ColorRed}, draw(a, 6, 5))
+}
+
+func TestComet(t *testing.T) {
+	a := Comet(0x0000C8, 3)
+	assert.Equal(t, Frame{0x000064, 0x000096, 0x0000C8, 0, 0, 0x000032}, draw(a, 6, 2))
+}
+
+func TestArc(t *testing.T) {
+	progress := 1.0
+	a := Arc(ColorGreen, func() float64 { return progress })
+	assert.Equal(t, Frame{ColorGreen, ColorGreen, ColorGreen, ColorGreen}, draw(a, 4, 0))
+
+	progress = 0.6
+	assert.Equal(t, Frame{ColorGreen, ColorGreen, ColorGreen, 0}, draw(a, 4, 1))
+
+	progress = 0.01
+	assert.Equal(t, Frame{ColorGreen, 0, 0, 0}, draw(a, 4, 2))
+
+	progress = -1
+	assert.Equal(t, Frame{0, 0, 0, 0}, draw(a, 4, 3))
+}
+
+func TestSegments(t *testing.T) {
+	assert.Equal(t, Frame{ColorRed, ColorRed, ColorRed, ColorRed}, draw(Segments([]uint32{ColorRed}), 4, 0))
+
+	f := draw(Segments([]uint32{ColorRed, ColorGreen, ColorYellow}), ledCounts, 0)
+	assert.Equal(t, uint32(ColorRed), f[0])
+	assert.Equal(t, uint32(ColorRed), f[6])
+	assert.Equal(t, uint32(0), f[7])
+	assert.Equal(t, uint32(ColorGreen), f[8])
+	assert.Equal(t, uint32(0), f[15])
+	assert.Equal(t, uint32(ColorYellow), f[22])
+	assert.Equal(t, uint32(0), f[23])
+
+	// without room for gaps, every LED is its own segment.
+	assert.Equal(t, Frame{ColorRed, ColorGreen}, draw(Segments([]uint32{ColorRed, ColorGreen}), 2, 0))
+}
+
+func TestAnimate(t *testing.T) {
+	l := NewLedController()
+	leds := l.ws.Leds(0)
+	assert.Len(t, leds, ledCounts)
+
+	frames := make(chan int, 10)
+	l.Animate(time.Millisecond, func(f Frame, step int) bool {
+		if step == 3 {
+			close(frames)
+			return false
+		}
+		f[step] = ColorRed
+		frames <- step
+		return true
+	})
+
+	var steps []int
+	for step := range frames {
+		steps = append(steps, step)
+	}
+	assert.Equal(t, []int{0, 1, 2}, steps)
+
+	// the ring is cleared when the animation is over.
+	assert.Eventually(t, func() bool {
+		done := l.interruptor.Interrupt()
+		defer done()
+		return leds[2] == 0
+	}, 100*time.Millisecond, time.Millisecond)
+}
@@ -142,3 +142,78 @@ func (l *LedController) singleBreath(color uint32) error {
 	}
 	return nil
 }
+
+// Pulse the color in quick, sharp beats until interrupted. Used while waiting for something to happen, in contrast to
+// the slower breathing used when waiting for someone to act.
+func (l *LedController) Pulse(color uint32) {
+	done := l.interruptor.Interrupt()
+
+	go func() {
+		defer done()
+		defer l.clear()
+		for {
+			err := l.singlePulse(color)
+			if err != nil {
+				log.Debug("Stopping pulse: ", err)
+				break
+			}
+		}
+	}()
+}
+
+func (l *LedController) singlePulse(color uint32) error {
+	log.Debugf("Pulsing color: %06x", color)
+	tick := time.NewTicker(10 * time.Millisecond)
+	defer tick.Stop()
+
+	// ramp up quickly, cut the light and then stay dark for a moment.
+	for step := 0; step < 50; step++ {
+		if l.interruptor.IsInterrupted() {
+			log.Debug("Animation interrupted.")
+			return fmt.Errorf("animtion is interrupted")
+		}
+
+		light := uint32(0)
+		if step < 25 {
+			light = uint32(step * 4)
+		}
+		err := l.setColor(withBrightness(color, light))
+		if err != nil {
+			return err
+		}
+
+		<-tick.C
+	}
+	return nil
+}
+
+// Blink the color on and off at a steady pace until interrupted.
+func (l *LedController) Blink(color uint32) {
+	done := l.interruptor.Interrupt()
+
+	go func() {
+		defer done()
+		defer l.clear()
+
+		log.Debugf("Blinking color: %06x", color)
+		tick := time.NewTicker(50 * time.Millisecond)
+		defer tick.Stop()
+		for step := 0; ; step++ {
+			if l.interruptor.IsInterrupted() {
+				log.Debug("Stopping blink: animation interrupted.")
+				return
+			}
+
+			c := uint32(0)
+			if step%20 < 10 {
+				c = color
+			}
+			if err := l.setColor(c); err != nil {
+				log.Debug("Stopping blink: ", err)
+				return
+			}
+
+			<-tick.C
+		}
+	}()
+}
@@ -11,6 +11,7 @@ const (
 	ColorRed    = 0xFF0000
 	ColorYellow = 0xFFFF00
 	ColorGreen  = 0x00FF00
+	ColorIce    = 0x40C0FF
 )
 
 type wsEngine interface {
@@ -0,0 +1,161 @@
+package neopixel
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// patternInterval is how often the ring is redrawn while a pattern is playing.
+const patternInterval = 10 * time.Millisecond
+
+// Easing is how a pattern moves from one keyframe to the next.
+type Easing string
+
+const (
+	EaseLinear Easing = "linear"
+	// EaseStep jumps to the keyframe right away, and holds it for the duration of the keyframe.
+	EaseStep      Easing = "step"
+	EaseIn        Easing = "in"
+	EaseOut       Easing = "out"
+	EaseInOut     Easing = "in-out"
+	defaultEasing        = EaseLinear
+)
+
+// apply the easing to the progress of a transition, from 0 at the start to 1 at the end.
+func (e Easing) apply(p float64) float64 {
+	switch e {
+	case EaseStep:
+		return 1
+	case EaseIn:
+		return p * p
+	case EaseOut:
+		return 1 - (1-p)*(1-p)
+	case EaseInOut:
+		return (1 - math.Cos(p*math.Pi)) / 2
+	}
+	return p
+}
+
+func (e Easing) valid() bool {
+	switch e {
+	case "", EaseLinear, EaseStep, EaseIn, EaseOut, EaseInOut:
+		return true
+	}
+	return false
+}
+
+// Keyframe is a color that a pattern reaches at the end of the duration of the keyframe, starting from the keyframe
+// before it.
+type Keyframe struct {
+	Color uint32
+	// Brightness of the color, from 0 for off to 100 for the full color.
+	Brightness uint32
+	Duration   time.Duration
+	// Easing of the transition from the keyframe before. Defaults to EaseLinear.
+	Easing Easing
+}
+
+// Pattern is an effect described by keyframes, played on the whole ring. The first keyframe starts from the last one
+// if the pattern repeats, and from the ring being off otherwise.
+type Pattern struct {
+	Keyframes []Keyframe
+	// Repeat plays the pattern over and over until it is interrupted by another effect.
+	Repeat bool
+}
+
+// Validate checks that the pattern can be played.
+func (p Pattern) Validate() error {
+	if len(p.Keyframes) == 0 {
+		return fmt.Errorf("pattern has no keyframes")
+	}
+	for i, k := range p.Keyframes {
+		if k.Brightness > 100 {
+			return fmt.Errorf("brightness of keyframe %d is above 100", i)
+		}
+		if k.Duration < 0 {
+			return fmt.Errorf("duration of keyframe %d is negative", i)
+		}
+		if !k.Easing.valid() {
+			return fmt.Errorf("keyframe %d has unknown easing %q", i, k.Easing)
+		}
+	}
+	if p.Duration() <= 0 {
+		return fmt.Errorf("pattern has no duration")
+	}
+	return nil
+}
+
+// Duration is the time it takes to play the pattern once.
+func (p Pattern) Duration() time.Duration {
+	var d time.Duration
+	for _, k := range p.Keyframes {
+		d += k.Duration
+	}
+	return d
+}
+
+// colorAt returns the color of the ring at the time since the pattern started playing. It returns false once a pattern
+// that does not repeat is over.
+func (p Pattern) colorAt(t time.Duration) (uint32, bool) {
+	total := p.Duration()
+	if t >= total {
+		if !p.Repeat {
+			return 0, false
+		}
+		t %= total
+	}
+
+	from := uint32(0)
+	if p.Repeat {
+		from = p.Keyframes[len(p.Keyframes)-1].color()
+	}
+	for _, k := range p.Keyframes {
+		if t < k.Duration {
+			easing := k.Easing
+			if easing == "" {
+				easing = defaultEasing
+			}
+			return mix(from, k.color(), easing.apply(float64(t)/float64(k.Duration))), true
+		}
+		t -= k.Duration
+		from = k.color()
+	}
+	return from, true
+}
+
+func (k Keyframe) color() uint32 {
+	return withBrightness(k.Color, k.Brightness)
+}
+
+// mix the colors, from all of a at 0 to all of b at 1.
+func mix(a, b uint32, f float64) uint32 {
+	channel := func(shift uint32) uint32 {
+		ca, cb := float64((a>>shift)&0xff), float64((b>>shift)&0xff)
+		return uint32(math.Round(ca+(cb-ca)*f)) << shift
+	}
+	return channel(16) | channel(8) | channel(0)
+}
+
+// Play the pattern on the ring. A pattern that repeats is played until it is interrupted, and Play returns right away.
+// Otherwise, Play returns once the pattern is over.
+func (l *LedController) Play(p Pattern) {
+	var start time.Time
+	stopped := l.animate(patternInterval, func(f Frame, step int) bool {
+		if step == 0 {
+			start = time.Now()
+		}
+		c, ok := p.colorAt(time.Since(start))
+		if !ok {
+			return false
+		}
+		for i := range f {
+			f[i] = c
+		}
+		return true
+	})
+
+	if !p.Repeat {
+		<-stopped
+	}
+}
@@ -0,0 +1,73 @@
+package neopixel
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestPattern_Linear(t *testing.T) {
+	p := Pattern{Keyframes: []Keyframe{
+		{Color: 0xC80000, Brightness: 100, Duration: 100 * time.Millisecond},
+		{Color: 0xC80000, Brightness: 0, Duration: 100 * time.Millisecond, Easing: EaseLinear},
+	}}
+	require.NoError(t, p.Validate())
+	assert.Equal(t, 200*time.Millisecond, p.Duration())
+
+	at := func(ms int) uint32 {
+		c, ok := p.colorAt(time.Duration(ms) * time.Millisecond)
+		require.True(t, ok)
+		return c
+	}
+	assert.Equal(t, uint32(0x000000), at(0))
+	assert.Equal(t, uint32(0x640000), at(50))
+	assert.Equal(t, uint32(0xC80000), at(100))
+	assert.Equal(t, uint32(0x320000), at(175))
+
+	_, ok := p.colorAt(200 * time.Millisecond)
+	assert.False(t, ok)
+}
+
+func TestPattern_StepRepeat(t *testing.T) {
+	p := Pattern{Repeat: true, Keyframes: []Keyframe{
+		{Color: ColorRed, Brightness: 100, Duration: 10 * time.Millisecond, Easing: EaseStep},
+		{Color: ColorGreen, Brightness: 100, Duration: 20 * time.Millisecond, Easing: EaseStep},
+	}}
+	require.NoError(t, p.Validate())
+
+	for ms, expected := range map[int]uint32{0: ColorRed, 9: ColorRed, 10: ColorGreen, 29: ColorGreen, 30: ColorRed, 45: ColorGreen} {
+		c, ok := p.colorAt(time.Duration(ms) * time.Millisecond)
+		assert.True(t, ok)
+		assert.Equal(t, expected, c, "at %dms", ms)
+	}
+}
+
+func TestPattern_Easing(t *testing.T) {
+	for _, e := range []Easing{EaseLinear, EaseIn, EaseOut, EaseInOut} {
+		assert.InDelta(t, 0, e.apply(0), 0.001, e)
+		assert.InDelta(t, 1, e.apply(1), 0.001, e)
+	}
+	assert.Less(t, EaseIn.apply(0.5), 0.5)
+	assert.Greater(t, EaseOut.apply(0.5), 0.5)
+	assert.InDelta(t, 0.5, EaseInOut.apply(0.5), 0.001)
+}
+
+func TestPattern_Validate(t *testing.T) {
+	assert.Error(t, Pattern{}.Validate())
+	assert.Error(t, Pattern{Keyframes: []Keyframe{{Brightness: 100}}}.Validate())
+	assert.Error(t, Pattern{Keyframes: []Keyframe{{Brightness: 101, Duration: time.Second}}}.Validate())
+	assert.Error(t, Pattern{Keyframes: []Keyframe{{Duration: time.Second, Easing: "bounce"}}}.Validate())
+	assert.NoError(t, Pattern{Keyframes: []Keyframe{{Duration: time.Second}}}.Validate())
+}
+
+func TestPlay(t *testing.T) {
+	l := NewLedController()
+	p := Pattern{Keyframes: []Keyframe{{Color: ColorRed, Brightness: 100, Duration: 30 * time.Millisecond, Easing: EaseStep}}}
+
+	start := time.Now()
+	l.Play(p)
+	// a pattern that does not repeat is played to the end before returning, and the ring is cleared after it.
+	assert.GreaterOrEqual(t, time.Since(start), 30*time.Millisecond)
+	assert.Equal(t, uint32(0), l.ws.Leds(0)[0])
+}
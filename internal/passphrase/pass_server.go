@@ -12,13 +12,16 @@ import (
 )
 
 type Server struct {
+	display  lcd.Display
 	passChan chan string
 	server   http.Server
 	running  bool
 }
 
-func NewServer() *Server {
+// NewServer creates a server that shows the address to enter the passphrase at on the display.
+func NewServer(display lcd.Display) *Server {
 	return &Server{
+		display:  display,
 		passChan: make(chan string),
 		server:   http.Server{Addr: ":8090"},
 	}
@@ -61,12 +64,12 @@ func (p *Server) showCurrentIP() {
 			break
 		}
 		if i == 0 {
-			lcd.Print("Awaiting network", "")
+			lcd.Print(p.display, "Awaiting network", "")
 		}
 		<-time.After(6 * time.Second)
 	}
 
-	lcd.Print("Enter passphrase", fmt.Sprintf("@%v", ip))
+	lcd.Print(p.display, "Enter passphrase", fmt.Sprintf("@%v", ip))
 }
 
 const form = `